@@ -87,6 +87,9 @@ func decodeTableMVCC(_ *cobra.Command, args []string) error {
 }
 
 func getTableInfo(id string) (tblInfo *model.TableInfo, err error) {
+	if len(schemaFile) != 0 {
+		return getTableInfoFromSnapshot(id)
+	}
 	url := ""
 	if strings.Contains(id, ".") {
 		fields := strings.Split(id, ".")
@@ -100,8 +103,12 @@ func getTableInfo(id string) (tblInfo *model.TableInfo, err error) {
 	}
 
 	url = schema + "://" + host.String() + ":" + strconv.Itoa(int(port)) + "/" + "schema" + url
-	var resp *http.Response
-	resp, err = ctlClient.Get(url)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+	req = withTimeout(req)
+	resp, err := ctlClient.Do(req)
 	if err != nil {
 		return
 	}
@@ -115,13 +122,34 @@ func getTableInfo(id string) (tblInfo *model.TableInfo, err error) {
 		return
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.Errorf("get table info status code is no ok. body: %s", string(body))
+		return nil, httpStatusError(url, resp.StatusCode, body)
 	}
 	tblInfo = &model.TableInfo{}
 	err = json.Unmarshal(body, tblInfo)
 	return tblInfo, err
 }
 
+// getTableInfoFromSnapshot is like getTableInfo, but gets the table info from --schema-file.
+func getTableInfoFromSnapshot(id string) (*model.TableInfo, error) {
+	snap, err := getSchemaSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(id, ".") {
+		fields := strings.Split(id, ".")
+		if len(fields) != 2 {
+			return nil, errors.Errorf("wrong table name. need like: test.t1")
+		}
+		return snap.findTableByName(fields[0], fields[1])
+	}
+	tableID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, errors.Errorf("wrong table id %s", id)
+	}
+	_, tblInfo, err := snap.findTableByID(tableID)
+	return tblInfo, err
+}
+
 func decodeMVCC(tbl *model.TableInfo, base64Str string) (string, error) {
 	if len(base64Str) == 0 {
 		return "", errors.Errorf("no data?")
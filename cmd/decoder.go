@@ -186,6 +186,90 @@ func decodeTableRow(buf []byte) (int64, int64, error) {
 	return 0, 0, errors.Errorf("illegal code format")
 }
 
+const (
+	keyTypeMeta    = "meta"
+	keyTypeTable   = "table"
+	keyTypeRecord  = "record"
+	keyTypeIndex   = "index"
+	keyTypeUnknown = "unknown"
+)
+
+// keyInfo is the decoded form of a raw key, the key may be only a prefix of a row key or an index key.
+type keyInfo struct {
+	Type          string   `json:"type,omitempty"`
+	TableID       int64    `json:"table_id,omitempty"`
+	DBName        string   `json:"db_name,omitempty"`
+	TableName     string   `json:"table_name,omitempty"`
+	PartitionName string   `json:"partition_name,omitempty"`
+	Handle        *int64   `json:"handle,omitempty"`
+	IndexID       int64    `json:"index_id,omitempty"`
+	IndexName     string   `json:"index_name,omitempty"`
+	IndexValues   []string `json:"index_values,omitempty"`
+}
+
+// decodeKeyPrefix decodes a raw key that may be truncated, such as the start key or end key of a region.
+func decodeKeyPrefix(raw []byte) *keyInfo {
+	k := &keyInfo{}
+	if len(raw) == 0 {
+		return k
+	}
+	if raw[0] == 'm' {
+		k.Type = keyTypeMeta
+		return k
+	}
+	if raw[0] != 't' || len(raw) < 9 {
+		k.Type = keyTypeUnknown
+		return k
+	}
+	_, tableID, err := codec.DecodeInt(raw[1:9])
+	if err != nil {
+		k.Type = keyTypeUnknown
+		return k
+	}
+	k.Type, k.TableID = keyTypeTable, tableID
+	rest := raw[9:]
+	if len(rest) < 2 || rest[0] != '_' {
+		return k
+	}
+	switch rest[1] {
+	case 'r':
+		k.Type = keyTypeRecord
+		if len(rest) >= 10 {
+			if _, handle, err := codec.DecodeInt(rest[2:10]); err == nil {
+				k.Handle = &handle
+			}
+		}
+	case 'i':
+		k.Type = keyTypeIndex
+		if len(rest) >= 10 {
+			if _, indexID, err := codec.DecodeInt(rest[2:10]); err == nil {
+				k.IndexID = indexID
+			}
+			if values, err := decodeIndexValue(rest[10:]); err == nil {
+				for _, iv := range values {
+					k.IndexValues = append(k.IndexValues, iv.valueStr)
+				}
+			}
+		}
+	}
+	return k
+}
+
+// describeKey returns a short human-readable form of a table row or table index key.
+func describeKey(raw []byte) string {
+	if tableID, rowID, err := decodeTableRow(raw); err == nil {
+		return fmt.Sprintf("table_id: %d, handle: %d", tableID, rowID)
+	}
+	if tableID, indexID, values, err := decodeTableIndex(raw); err == nil {
+		strs := make([]string, 0, len(values))
+		for _, iv := range values {
+			strs = append(strs, iv.valueStr)
+		}
+		return fmt.Sprintf("table_id: %d, index_id: %d, index_values: [%s]", tableID, indexID, strings.Join(strs, ", "))
+	}
+	return "unknown key format"
+}
+
 func decodeKeyFunc(c *cobra.Command, args []string) error {
 	if len(args) > 1 {
 		return fmt.Errorf("too many arguments")
@@ -76,3 +76,24 @@ func (s *decoderTestSuite) TestBase64Decode(c *C) {
 		"table_id: 64\n"+
 		"row_id: 1\n")
 }
+
+func (s *decoderTestSuite) TestDecodeKeyPrefix(c *C) {
+	k := decodeKeyPrefix(nil)
+	c.Assert(k.Type, Equals, "")
+	k = decodeKeyPrefix([]byte("mDDL"))
+	c.Assert(k.Type, Equals, keyTypeMeta)
+	k = decodeKeyPrefix([]byte("t\x80\x00\x00\x00\x00\x00\x00\x5f"))
+	c.Assert(k.Type, Equals, keyTypeTable)
+	c.Assert(k.TableID, Equals, int64(95))
+	k = decodeKeyPrefix([]byte("t\x80\x00\x00\x00\x00\x00\x07\x8f_r\x80\x00\x00\x00\x00\x08\x3b\xba"))
+	c.Assert(k.Type, Equals, keyTypeRecord)
+	c.Assert(k.TableID, Equals, int64(1935))
+	c.Assert(*k.Handle, Equals, int64(539578))
+	k = decodeKeyPrefix([]byte("t\x80\x00\x00\x00\x00\x00\x00\x5f_i\x80\x00\x00\x00\x00\x00\x00\x01\x03\x80\x00\x00\x00\x00\x00\x00\x02"))
+	c.Assert(k.Type, Equals, keyTypeIndex)
+	c.Assert(k.IndexID, Equals, int64(1))
+	c.Assert(k.IndexValues, DeepEquals, []string{"2"})
+	k = decodeKeyPrefix([]byte("t\x80\x00\x00\x00\x00\x00\x00\x5f_i"))
+	c.Assert(k.Type, Equals, keyTypeIndex)
+	c.Assert(k.IndexID, Equals, int64(0))
+}
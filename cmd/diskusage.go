@@ -0,0 +1,174 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// disk-usage command flags
+var (
+	diskUsageDB          string
+	diskUsageTop         int
+	diskUsageConcurrency int
+)
+
+// tableDiskUsage is the disk usage of a table, the size is in MiB as reported by PD.
+type tableDiskUsage struct {
+	db    string
+	table string
+	size  int64
+	err   error
+}
+
+// diskUsageRootCmd represents the disk-usage command
+var diskUsageRootCmd = &cobra.Command{
+	Use:   usageSurffix,
+	Short: "Disk usage of all tables",
+	Long: `tidb-ctl disk-usage [--database(-d) [database name]] [--top(-n) [N]] [--concurrency(-c) [N]]
+Show the tables using the most disk space and the disk usage of each database.`,
+	RunE: getDiskUsage,
+}
+
+func init() {
+	diskUsageRootCmd.Flags().StringVarP(&diskUsageDB, dbFlagName, "d", "", "only show the tables in the database")
+	diskUsageRootCmd.Flags().IntVarP(&diskUsageTop, "top", "n", 10, "the number of tables to show, 0 means all tables")
+	diskUsageRootCmd.Flags().IntVarP(&diskUsageConcurrency, "concurrency", "c", 8, "the number of concurrent requests")
+}
+
+func getDiskUsage(c *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("too many arguments")
+	}
+	if diskUsageConcurrency <= 0 {
+		return fmt.Errorf("concurrency should be positive")
+	}
+	var dbNames []string
+	if len(diskUsageDB) != 0 {
+		dbNames = append(dbNames, diskUsageDB)
+	} else {
+		dbs, err := getDBInfos()
+		if err != nil {
+			return err
+		}
+		for _, db := range dbs {
+			dbNames = append(dbNames, db.Name.O)
+		}
+	}
+	var usages []*tableDiskUsage
+	for _, db := range dbNames {
+		tables, err := getTableInfos(db)
+		if err != nil {
+			return err
+		}
+		for _, tbl := range tables {
+			if tbl.IsView() || tbl.IsSequence() {
+				continue
+			}
+			usages = append(usages, &tableDiskUsage{db: db, table: tbl.Name.O})
+		}
+	}
+
+	fetchDiskUsages(usages, diskUsageConcurrency)
+	return printDiskUsages(c, usages, diskUsageTop)
+}
+
+// fetchDiskUsages fills the sizes of the tables with at most concurrency requests in flight.
+func fetchDiskUsages(usages []*tableDiskUsage, concurrency int) {
+	ch := make(chan *tableDiskUsage)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range ch {
+				u.err = httpGetJSON(tablePrefix+u.db+"/"+u.table+"/"+usageSurffix, &u.size)
+			}
+		}()
+	}
+	for _, u := range usages {
+		ch <- u
+	}
+	close(ch)
+	wg.Wait()
+}
+
+// printDiskUsages prints the disk usages, an error is returned if any table fails.
+func printDiskUsages(c *cobra.Command, usages []*tableDiskUsage, top int) error {
+	type dbDiskUsage struct {
+		name   string
+		size   int64
+		tables int
+	}
+	var (
+		succeeded []*tableDiskUsage
+		failed    []*tableDiskUsage
+		total     int64
+		dbs       []*dbDiskUsage
+	)
+	dbMap := make(map[string]*dbDiskUsage)
+	for _, u := range usages {
+		if u.err != nil {
+			failed = append(failed, u)
+			continue
+		}
+		succeeded = append(succeeded, u)
+		total += u.size
+		db, ok := dbMap[u.db]
+		if !ok {
+			db = &dbDiskUsage{name: u.db}
+			dbMap[u.db] = db
+			dbs = append(dbs, db)
+		}
+		db.size += u.size
+		db.tables++
+	}
+	sort.SliceStable(succeeded, func(i, j int) bool { return succeeded[i].size > succeeded[j].size })
+	sort.SliceStable(dbs, func(i, j int) bool { return dbs[i].size > dbs[j].size })
+	if top > 0 && len(succeeded) > top {
+		succeeded = succeeded[:top]
+	}
+
+	width := 0
+	for _, u := range succeeded {
+		if l := len(u.db) + len(u.table) + 1; l > width {
+			width = l
+		}
+	}
+	for _, db := range dbs {
+		if len(db.name) > width {
+			width = len(db.name)
+		}
+	}
+	c.Printf("top %d tables:\n", len(succeeded))
+	for _, u := range succeeded {
+		c.Printf("  %-*s  %s\n", width, u.db+"."+u.table, humanizeBytes(float64(u.size)*1024*1024))
+	}
+	c.Println("databases:")
+	for _, db := range dbs {
+		c.Printf("  %-*s  %s (%d tables)\n", width, db.name, humanizeBytes(float64(db.size)*1024*1024), db.tables)
+	}
+	c.Printf("total: %s (%d tables)\n", humanizeBytes(float64(total)*1024*1024), len(usages)-len(failed))
+	for _, u := range failed {
+		c.Printf("failed to get the disk usage of %s.%s: %v\n", u.db, u.table, u.err)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to get the disk usage of %d of %d tables", len(failed), len(usages))
+	}
+	return nil
+}
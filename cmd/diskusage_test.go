@@ -0,0 +1,128 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&diskUsageTestSuite{})
+
+type diskUsageTestSuite struct{}
+
+func (s *diskUsageTestSuite) TearDownTest(c *C) {
+	diskUsageDB, diskUsageTop, diskUsageConcurrency = "", 10, 8
+}
+
+// newFakeDiskUsageServer serves the schemas and the disk usages of the tables, the table failed returns an error.
+// The max number of the disk usage requests in flight is saved to maxInFlight.
+func newFakeDiskUsageServer(c *C, failed string, maxInFlight *int32) (*httptest.Server, []string) {
+	sizes := map[string]string{"test/t1": "100", "test/t2": "3", "app/a1": "50", "app/a2": "0"}
+	var inFlight int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp string
+		path := r.URL.EscapedPath()
+		switch {
+		case path == "/schema":
+			resp = `[{"id":1,"db_name":{"O":"test","L":"test"}},{"id":2,"db_name":{"O":"app","L":"app"}}]`
+		case path == "/schema/test":
+			resp = `[{"id":45,"name":{"O":"t1","L":"t1"}},{"id":46,"name":{"O":"t2","L":"t2"}},` +
+				`{"id":47,"name":{"O":"v","L":"v"},"view":{"view_select":"select 1"}}]`
+		case path == "/schema/app":
+			resp = `[{"id":48,"name":{"O":"a1","L":"a1"}},{"id":49,"name":{"O":"a2","L":"a2"}}]`
+		case strings.HasSuffix(path, "/"+usageSurffix):
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				max := atomic.LoadInt32(maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(maxInFlight, max, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			table := strings.TrimSuffix(strings.TrimPrefix(path, "/"+tablePrefix), "/"+usageSurffix)
+			if table == failed {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, err := w.Write([]byte("pd is unavailable"))
+				c.Assert(err, IsNil)
+				return
+			}
+			resp = sizes[table]
+		default:
+			c.Fatalf("unexpected path %s", path)
+		}
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(resp))
+		c.Assert(err, IsNil)
+	}))
+	u, err := url.Parse(ts.URL)
+	c.Assert(err, IsNil)
+	uArr := strings.Split(u.Host, ":")
+	return ts, []string{"-H", uArr[0], "-P", uArr[1]}
+}
+
+func (s *diskUsageTestSuite) TestDiskUsage(c *C) {
+	var maxInFlight int32
+	ts, hostArgs := newFakeDiskUsageServer(c, "", &maxInFlight)
+	defer ts.Close()
+	cmd := initCommand()
+	args := append([]string{"disk-usage", "-n", "3", "-c", "2"}, hostArgs...)
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `top 3 tables:
+  test.t1  100.0 MiB
+  app.a1   50.0 MiB
+  test.t2  3.0 MiB
+databases:
+  test     103.0 MiB (2 tables)
+  app      50.0 MiB (2 tables)
+total: 153.0 MiB (4 tables)
+`)
+	c.Assert(atomic.LoadInt32(&maxInFlight) <= 2, IsTrue)
+
+	args = append([]string{"disk-usage", "-d", "app", "-n", "0"}, hostArgs...)
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `top 2 tables:
+  app.a1  50.0 MiB
+  app.a2  0 B
+databases:
+  app     50.0 MiB (2 tables)
+total: 50.0 MiB (2 tables)
+`)
+}
+
+func (s *diskUsageTestSuite) TestDiskUsageFailure(c *C) {
+	var maxInFlight int32
+	ts, hostArgs := newFakeDiskUsageServer(c, "test/t2", &maxInFlight)
+	defer ts.Close()
+	cmd := initCommand()
+	args := append([]string{"disk-usage", "-d", "test"}, hostArgs...)
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, ErrorMatches, "failed to get the disk usage of 1 of 2 tables")
+	c.Assert(string(output), Matches, `(?s)top 1 tables:
+  test.t1  100.0 MiB
+databases:
+  test     100.0 MiB \(1 tables\)
+total: 100.0 MiB \(1 tables\)
+failed to get the disk usage of test.t2: HTTP 500 .*pd is unavailable
+.*`)
+}
@@ -14,41 +14,77 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 
 	"github.com/pingcap/errors"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 type parameter struct {
 	// It is parameter for api_grpc_gateway
 	Key      string `json:"key"`
 	RangeEnd string `json:"range_end"`
+	Limit    int64  `json:"limit,omitempty"`
+	Revision int64  `json:"revision,omitempty"`
+	KeysOnly bool   `json:"keys_only,omitempty"`
 }
 
+// etcd get and ls command flags
+var (
+	etcdPrefix       string
+	etcdIsPrefix     bool
+	etcdKeysOnly     bool
+	etcdListKeysOnly bool
+	etcdLimit        int64
+	etcdRevision     int64
+)
+
 var (
 	rangeQueryPrefix           = "/v3/kv/range"
 	rangeDelPrefix             = "/v3/kv/deleterange"
 	putPrefix                  = "/v3/kv/put"
 	ddlAllSchemaVersionsPrefix = "/tidb/ddl/all_schema_versions/"
+	ddlOwnerKeyPrefix          = "/tidb/ddl/fg/owner/"
+	authPrefix                 = "/v3/auth/authenticate"
+	grpcGatewayPrefix          = "/v3/"
+)
+
+// etcd authentication flags
+var (
+	etcdUser         string
+	etcdPasswordFile string
+	// etcdToken is attached to the requests of grpc_gateway if the etcd authentication is enabled.
+	etcdToken string
 )
 
 // newEtcdCommand returns a etcd subcommand of rootCmd.
 func newEtcdCommand() *cobra.Command {
 	m := &cobra.Command{
-		Use:   "etcd",
-		Short: "control the info about etcd by grpc_gateway",
+		Use:               "etcd",
+		Short:             "control the info about etcd by grpc_gateway",
+		PersistentPreRunE: authenticateEtcd,
 	}
+	m.PersistentFlags().StringVar(&etcdUser, "etcd-user", "", "the user of etcd if the etcd authentication is enabled")
+	m.PersistentFlags().StringVar(&etcdPasswordFile, "etcd-password-file", "", "the file containing the password of --etcd-user, the password is prompted if not set")
 	m.AddCommand(newShowDDLInfoCommand())
 	m.AddCommand(newDelKeyCommand())
 	m.AddCommand(newPutKeyCommand())
+	m.AddCommand(newDelOwnerCommand())
+	m.AddCommand(newDelSchemaCommand())
+	m.AddCommand(newGetCommand())
+	m.AddCommand(newListCommand())
+	m.AddCommand(newWatchCommand())
+	m.AddCommand(newRestoreCommand())
 	return m
 }
 
@@ -69,6 +105,29 @@ func newDelKeyCommand() *cobra.Command {
 		Short: "Delete the key associated with DDL by `delkey [key]`",
 		Run:   delKeyCommandFunc,
 	}
+	addMutationFlags(m)
+	return m
+}
+
+// newDelOwnerCommand returns a delete DDL owner campaign subcommand of EtcdCommand.
+func newDelOwnerCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "delowner [LeaseID]",
+		Short: "delete DDL Owner Campaign by LeaseID",
+		Run:   delOwnerCommandFunc,
+	}
+	addMutationFlags(m)
+	return m
+}
+
+// newDelSchemaCommand returns a delete schema version subcommand of EtcdCommand.
+func newDelSchemaCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "delschema [DDLID]",
+		Short: "delete schema version by DDLID",
+		Run:   delSchemaCommandFunc,
+	}
+	addMutationFlags(m)
 	return m
 }
 
@@ -79,9 +138,41 @@ func newPutKeyCommand() *cobra.Command {
 		Short: "[ONLY FOR TEST!] put a key in the path of TiDB schema versions by `putkey [key] [value]`",
 		Run:   putKeyCommandFunc,
 	}
+	addMutationFlags(m)
 	return m
 }
 
+// newGetCommand returns a get subcommand of EtcdCommand.
+func newGetCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "get",
+		Short: "Get the key-values by `get [key]`, or the key-values with the prefix by `get --prefix [prefix]`",
+		Run:   getCommandFunc,
+	}
+	m.Flags().BoolVar(&etcdIsPrefix, "prefix", false, "get the key-values with the key as prefix")
+	m.Flags().BoolVar(&etcdKeysOnly, "keys-only", false, "only get the keys without values")
+	addRangeFlags(m)
+	return m
+}
+
+// newListCommand returns a list keys subcommand of EtcdCommand.
+func newListCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "ls",
+		Short: "List the keys with the prefix by `ls --prefix [prefix]`",
+		Run:   listCommandFunc,
+	}
+	m.Flags().StringVar(&etcdPrefix, "prefix", "/tidb/", "the prefix of keys")
+	m.Flags().BoolVar(&etcdListKeysOnly, "keys-only", true, "only list the keys without values")
+	addRangeFlags(m)
+	return m
+}
+
+func addRangeFlags(m *cobra.Command) {
+	m.Flags().Int64Var(&etcdLimit, "limit", 0, "the max number of keys, 0 means no limit")
+	m.Flags().Int64Var(&etcdRevision, "rev", 0, "the revision to read at, 0 means the latest revision")
+}
+
 func showDDLInfoCommandFunc(cmd *cobra.Command, args []string) {
 	res, err := getDDLInfo()
 	if err != nil {
@@ -98,7 +189,6 @@ func delKeyCommandFunc(cmd *cobra.Command, args []string) {
 	}
 
 	key := args[0]
-	ddlOwnerKeyPrefix := "/tidb/ddl/fg/owner/"
 	if !(strings.HasPrefix(key, ddlOwnerKeyPrefix) || strings.HasPrefix(key, ddlAllSchemaVersionsPrefix)) {
 		cmd.Println("This function only for delete the key-value about DDL")
 		return
@@ -107,28 +197,84 @@ func delKeyCommandFunc(cmd *cobra.Command, args []string) {
 		cmd.Println("Please input the key wanted to delete.")
 		return
 	}
-	var para = &parameter{
-		Key: base64Encode(key),
+	delKeyWithPrefix(cmd, "", key)
+}
+
+func delOwnerCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Println("Only one argument!")
+		return
 	}
+	// The campaign key is the owner key prefix with the lease ID in hex.
+	leaseID, err := strconv.ParseUint(strings.TrimPrefix(args[0], "0x"), 16, 64)
+	if err != nil {
+		cmd.Printf("Invalid LeaseID %s, it should be a hex number.\n", args[0])
+		return
+	}
+	delKeyWithPrefix(cmd, ddlOwnerKeyPrefix, ddlOwnerKeyPrefix+strconv.FormatUint(leaseID, 16))
+}
 
-	reqData, err := json.Marshal(para)
+func delSchemaCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Println("Only one argument!")
+		return
+	}
+	if len(args[0]) == 0 || strings.Contains(args[0], "/") {
+		cmd.Printf("Invalid DDLID %s.\n", args[0])
+		return
+	}
+	delKeyWithPrefix(cmd, ddlAllSchemaVersionsPrefix, ddlAllSchemaVersionsPrefix+args[0])
+}
+
+// delKeyWithPrefix deletes the key after confirmation and verifies the deletion.
+// If the key is not found, the existing keys with the prefix are shown when the prefix is not empty.
+func delKeyWithPrefix(cmd *cobra.Command, prefix, key string) {
+	current, err := getKVs(newRangeParameter(key, ""))
 	if err != nil {
 		cmd.Printf("Failed to delete key: %v\n", err)
 		return
 	}
-	req, err := getRequest(rangeDelPrefix, http.MethodPost, "application/json",
-		bytes.NewBuffer(reqData))
+	if len(current) == 0 {
+		cmd.Printf("The key %s is not found.\n", key)
+		if len(prefix) == 0 {
+			return
+		}
+		para := newRangeParameter(prefix, prefixRangeEnd(prefix))
+		para.KeysOnly = true
+		existing, err := getKVs(para)
+		if err != nil {
+			cmd.Printf("Failed to list keys with prefix %s: %v\n", prefix, err)
+			return
+		}
+		cmd.Printf("The existing keys with prefix %s:\n", prefix)
+		for _, kv := range existing {
+			cmd.Printf("  %s\n", kv.Key)
+		}
+		return
+	}
+	var deleted int64
+	done, err := runMutation(cmd, current, "will delete "+key, func() (err error) {
+		deleted, err = deleteKey(key)
+		return err
+	})
 	if err != nil {
 		cmd.Printf("Failed to delete key: %v\n", err)
 		return
 	}
-	res, err := dial(req)
+	if !done {
+		return
+	}
+	cmd.Printf("deleted %d keys\n", deleted)
+	remained, err := getKVs(newRangeParameter(key, ""))
 	if err != nil {
-		cmd.Printf("Failed to delete key: %v\n", err)
+		cmd.Printf("Failed to verify the deletion: %v\n", err)
 		return
 	}
-
-	cmd.Println(res)
+	if len(remained) != 0 {
+		cmd.Printf("The key %s still exists with value %s, it may be put again by TiDB.\n", key, remained[0].Value)
+		return
+	}
+	cmd.Printf("verified that %s is deleted\n", key)
 }
 
 func putKeyCommandFunc(cmd *cobra.Command, args []string) {
@@ -141,33 +287,104 @@ func putKeyCommandFunc(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	var putParameter struct {
-		Key   string `json:"key"`
-		Value string `json:"value"`
-	}
-	putParameter.Key = base64Encode(ddlAllSchemaVersionsPrefix + args[0])
-	putParameter.Value = base64Encode(args[1])
-
-	reqData, err := json.Marshal(putParameter)
+	key := ddlAllSchemaVersionsPrefix + args[0]
+	current, err := getKVs(newRangeParameter(key, ""))
 	if err != nil {
 		cmd.Printf("Failed to put key: %v\n", err)
 		return
 	}
-	req, err := getRequest(putPrefix, http.MethodPost, "application/json",
-		bytes.NewBuffer(reqData))
+	done, err := runMutation(cmd, current, "will put "+key+" = "+args[1], func() error {
+		return putKV([]byte(key), []byte(args[1]), "")
+	})
 	if err != nil {
 		cmd.Printf("Failed to put key: %v\n", err)
 		return
 	}
-	res, err := dial(req)
+	if done {
+		cmd.Println("put key successfully")
+	}
+}
+
+func getCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Println("Only one argument!")
+		return
+	}
+	var rangeEnd string
+	if etcdIsPrefix {
+		rangeEnd = prefixRangeEnd(args[0])
+	}
+	para := newRangeParameter(args[0], rangeEnd)
+	para.Limit, para.Revision, para.KeysOnly = etcdLimit, etcdRevision, etcdKeysOnly
+	res, err := getRange(para)
 	if err != nil {
-		cmd.Printf("Failed to put key: %v\n", err)
+		cmd.Printf("Failed to get key: %v\n", err)
+		return
+	}
+	res, err = formatJSONAndBase64Decode(res)
+	if err != nil {
+		cmd.Printf("Failed to get key: %v\n", err)
 		return
 	}
+	cmd.Println(res)
+}
 
+func listCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Println("No argument is needed, use --prefix instead!")
+		return
+	}
+	para := newRangeParameter(etcdPrefix, prefixRangeEnd(etcdPrefix))
+	para.Limit, para.Revision, para.KeysOnly = etcdLimit, etcdRevision, etcdListKeysOnly
+	res, err := getRange(para)
+	if err != nil {
+		cmd.Printf("Failed to list keys: %v\n", err)
+		return
+	}
+	res, err = formatJSONAndBase64Decode(res)
+	if err != nil {
+		cmd.Printf("Failed to list keys: %v\n", err)
+		return
+	}
 	cmd.Println(res)
 }
 
+// newRangeParameter returns the parameter of the range [key, rangeEnd), or the key only if rangeEnd is empty.
+func newRangeParameter(key, rangeEnd string) *parameter {
+	para := &parameter{Key: base64Encode(key)}
+	if len(rangeEnd) != 0 {
+		para.RangeEnd = base64Encode(rangeEnd)
+	}
+	return para
+}
+
+// getRange gets the key-values of the range parameter.
+func getRange(para *parameter) (string, error) {
+	reqData, err := json.Marshal(para)
+	if err != nil {
+		return "", err
+	}
+	req, err := getRequest(rangeQueryPrefix, http.MethodPost, "application/json",
+		bytes.NewBuffer(reqData))
+	if err != nil {
+		return "", err
+	}
+	return dial(req)
+}
+
+// prefixRangeEnd returns the range end of the keys with the prefix, which is the prefix with its last byte increased.
+// "\x00" is returned if the prefix is empty or made up of 0xff, which means all the keys after the prefix.
+func prefixRangeEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return "\x00"
+}
+
 func getDDLInfo() (string, error) {
 	st := "/tidb/ddl"
 	ed := "/tidb/ddm"
@@ -201,18 +418,91 @@ func getRequest(prefix string, method string, bodyType string, body io.Reader) (
 	if method == "" {
 		method = http.MethodGet
 	}
-	url := schema + "://" + pdHost.String() + ":" + strconv.Itoa(int(pdPort)) + prefix
+	url := pdSchema + "://" + pdHost.String() + ":" + strconv.Itoa(int(pdPort)) + prefix
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", bodyType)
+	if len(etcdToken) != 0 && strings.HasPrefix(prefix, grpcGatewayPrefix) {
+		req.Header.Set("Authorization", etcdToken)
+	}
 	return req, err
 }
 
+// authenticateEtcd sets up the HTTP clients and gets the token of --etcd-user for the following requests.
+func authenticateEtcd(cmd *cobra.Command, args []string) error {
+	if err := setupCommand(cmd, args); err != nil {
+		return err
+	}
+	etcdToken = ""
+	if len(etcdUser) == 0 {
+		return nil
+	}
+	password, err := readEtcdPassword(cmd)
+	if err != nil {
+		return err
+	}
+	var authParameter struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	authParameter.Name = etcdUser
+	authParameter.Password = password
+	reqData, err := json.Marshal(authParameter)
+	if err != nil {
+		return err
+	}
+	req, err := getRequest(authPrefix, http.MethodPost, "application/json", bytes.NewBuffer(reqData))
+	if err != nil {
+		return err
+	}
+	res, err := dial(req)
+	if err != nil {
+		return errors.Errorf("failed to authenticate etcd user %s: %v", etcdUser, err)
+	}
+	var authResponse struct {
+		Token string `json:"token"`
+	}
+	if err = json.Unmarshal([]byte(res), &authResponse); err != nil {
+		return err
+	}
+	if len(authResponse.Token) == 0 {
+		return errors.Errorf("failed to authenticate etcd user %s: no token is returned", etcdUser)
+	}
+	etcdToken = authResponse.Token
+	return nil
+}
+
+// readEtcdPassword reads the password from --etcd-password-file, or prompts for it without echo if stdin is a terminal.
+func readEtcdPassword(cmd *cobra.Command) (string, error) {
+	if len(etcdPasswordFile) != 0 {
+		data, err := ioutil.ReadFile(etcdPasswordFile)
+		if err != nil {
+			return "", errors.Errorf("could not read etcd password: %s", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	cmd.Printf("Password of etcd user %s: ", etcdUser)
+	if f, ok := cmd.InOrStdin().(*os.File); ok && terminal.IsTerminal(int(f.Fd())) {
+		password, err := terminal.ReadPassword(int(f.Fd()))
+		cmd.Println()
+		if err != nil {
+			return "", err
+		}
+		return string(password), nil
+	}
+	password, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(password, "\r\n"), nil
+}
+
 func dial(req *http.Request) (string, error) {
 	var res string
-	resp, err := ctlClient.Do(req)
+	req = withTimeout(req)
+	resp, err := pdClient.Do(req)
 	if err != nil {
 		return res, err
 	}
@@ -239,7 +529,7 @@ func genResponseError(r *http.Response) error {
 	if err != nil {
 		return err
 	}
-	return errors.Errorf("[%d] %s", r.StatusCode, res)
+	return httpStatusError(r.Request.URL.String(), r.StatusCode, res)
 }
 
 func formatJSONAndBase64Decode(str string) (string, error) {
@@ -247,6 +537,7 @@ func formatJSONAndBase64Decode(str string) (string, error) {
 		Count  string              `json:"count"`
 		Header map[string]string   `json:"header"`
 		Kvs    []map[string]string `json:"kvs"`
+		More   bool                `json:"more,omitempty"`
 	}
 
 	err := json.Unmarshal([]byte(str), &jsn)
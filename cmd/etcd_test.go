@@ -19,6 +19,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	. "github.com/pingcap/check"
@@ -51,62 +53,361 @@ func (s *etcdTestSuite) TestDDLInfo(c *C) {
 	c.Assert(err, IsNil)
 }
 
+// fakeEtcd serves the range, deleterange, put and lease timetolive APIs of grpc_gateway with the key-values in memory.
+type fakeEtcd struct {
+	c   *C
+	kvs map[string]string
+	// leases are the leases of the keys, ttls are the TTLs of the leases which are alive.
+	leases map[string]string
+	ttls   map[string]int64
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key      []byte `json:"key"`
+		RangeEnd []byte `json:"range_end"`
+		Value    []byte `json:"value"`
+		Lease    string `json:"lease"`
+		ID       string `json:"ID"`
+	}
+	f.c.Assert(json.NewDecoder(r.Body).Decode(&req), IsNil)
+	inRange := func(k string) bool {
+		if len(req.RangeEnd) == 0 {
+			return k == string(req.Key)
+		}
+		return k >= string(req.Key) && k < string(req.RangeEnd)
+	}
+	var resp interface{}
+	switch r.URL.EscapedPath() {
+	case rangeQueryPrefix:
+		var kvs []*etcdKV
+		for _, k := range sortedNames(f.keys()) {
+			if inRange(k) {
+				kvs = append(kvs, &etcdKV{Key: []byte(k), Value: []byte(f.kvs[k]), ModRevision: "1", Lease: f.leases[k]})
+			}
+		}
+		resp = map[string]interface{}{"kvs": kvs}
+	case rangeDelPrefix:
+		deleted := 0
+		for k := range f.kvs {
+			if inRange(k) {
+				delete(f.kvs, k)
+				delete(f.leases, k)
+				deleted++
+			}
+		}
+		resp = map[string]string{"deleted": strconv.Itoa(deleted)}
+	case putPrefix:
+		f.kvs[string(req.Key)] = string(req.Value)
+		if len(req.Lease) != 0 {
+			f.leases[string(req.Key)] = req.Lease
+		} else {
+			delete(f.leases, string(req.Key))
+		}
+		resp = map[string]string{}
+	case leaseTimeToLivePrefix:
+		ttl, ok := f.ttls[req.ID]
+		if !ok {
+			ttl = -1
+		}
+		resp = map[string]string{"ID": req.ID, "TTL": strconv.FormatInt(ttl, 10)}
+	default:
+		f.c.Fatalf("unexpected path %s", r.URL.EscapedPath())
+	}
+	data, err := json.Marshal(resp)
+	f.c.Assert(err, IsNil)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	f.c.Assert(err, IsNil)
+}
+
+func (f *fakeEtcd) keys() map[string]struct{} {
+	keys := make(map[string]struct{}, len(f.kvs))
+	for k := range f.kvs {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+func newFakeEtcdServer(c *C, kvs map[string]string) (*httptest.Server, []string) {
+	return serveFakeEtcd(c, &fakeEtcd{c: c, kvs: kvs, leases: make(map[string]string)})
+}
+
+func serveFakeEtcd(c *C, f *fakeEtcd) (*httptest.Server, []string) {
+	ts := httptest.NewServer(f)
+	u, err := url.Parse(ts.URL)
+	c.Assert(err, IsNil)
+	uArr := strings.Split(u.Host, ":")
+	return ts, []string{"-i", uArr[0], "-p", uArr[1]}
+}
+
 func (s *etcdTestSuite) TestDelKey(c *C) {
+	defer func() { etcdForce = false }()
+	testKey := "/tidb/ddl/all_schema_versions/12345"
+	kvs := map[string]string{testKey: "10"}
+	ts, pdArgs := newFakeEtcdServer(c, kvs)
+	defer ts.Close()
+	cmd := initCommand()
+	args := append([]string{"etcd", "delkey", "test"}, pdArgs...)
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "This function only for delete the key-value about DDL\n")
+
+	args = append([]string{"etcd", "delkey", testKey, "--dry-run"}, pdArgs...)
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "current key-values:\n  "+testKey+" = 10 (mod_revision: 1)\nwill delete "+testKey+"\ndry run, nothing is changed\n")
+	c.Assert(kvs, HasLen, 1)
+
+	// Abort without confirmation.
+	cmd.SetIn(strings.NewReader("n\n"))
+	args = append([]string{"etcd", "delkey", testKey, "--dry-run=false"}, pdArgs...)
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Matches, "(?s).*Are you sure to continue\\? \\[y/N\\]: aborted\n")
+	c.Assert(kvs, HasLen, 1)
+
+	backup := filepath.Join(c.MkDir(), "backup.json")
+	cmd.SetIn(strings.NewReader("y\n"))
+	args = append([]string{"etcd", "delkey", testKey, "--backup", backup}, pdArgs...)
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Matches, "(?s).*saved to "+backup+".*deleted 1 keys\nverified that "+testKey+" is deleted\n")
+	c.Assert(kvs, HasLen, 0)
+
+	args = append([]string{"etcd", "delkey", testKey}, pdArgs...)
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "The key "+testKey+" is not found.\n")
+
+	// The deleted key-value can be restored from the backup.
+	args = append([]string{"etcd", "restore", backup, "--yes"}, pdArgs...)
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	pd := pdArgs[1] + ":" + pdArgs[3]
+	c.Assert(string(output), Matches, "(?s).*will put 1 key-values saved from PD "+pd+" at .* into PD "+pd+":\n  "+testKey+" = 10\nrestored 1 key-values\n")
+	c.Assert(kvs, DeepEquals, map[string]string{testKey: "10"})
+
+	// The backup of another PD is restored only with --force.
+	otherKVs := map[string]string{}
+	otherTS, otherPDArgs := newFakeEtcdServer(c, otherKVs)
+	defer otherTS.Close()
+	args = append([]string{"etcd", "restore", backup, "--yes"}, otherPDArgs...)
+	_, _, err = executeCommandC(cmd, args...)
+	c.Assert(err, ErrorMatches, "the backup is saved from PD "+pd+" instead of .*, use --force to restore it anyway")
+	c.Assert(otherKVs, HasLen, 0)
+	_, _, err = executeCommandC(cmd, append(args, "--force")...)
+	c.Assert(err, IsNil)
+	c.Assert(otherKVs, DeepEquals, map[string]string{testKey: "10"})
+
+	_, _, err = executeCommandC(cmd, "etcd", "restore", filepath.Join(c.MkDir(), "missing.json"))
+	c.Assert(err, NotNil)
+}
+
+func (s *etcdTestSuite) TestDelOwnerAndSchema(c *C) {
+	ownerKey := ddlOwnerKeyPrefix + "694d7a6a8c3bd404"
+	schemaKey := ddlAllSchemaVersionsPrefix + "a4e3b4e1-6e3a-4c1b-9c6e-2f1c6e0b7d1e"
+	kvs := map[string]string{ownerKey: "a4e3b4e1-6e3a-4c1b-9c6e-2f1c6e0b7d1e", schemaKey: "20"}
+	ts, pdArgs := newFakeEtcdServer(c, kvs)
+	defer ts.Close()
+	cmd := initCommand()
+	args := append([]string{"etcd", "delowner", "xyz"}, pdArgs...)
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "Invalid LeaseID xyz, it should be a hex number.\n")
+
+	args = append([]string{"etcd", "delowner", "123"}, pdArgs...)
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "The key "+ddlOwnerKeyPrefix+"123 is not found.\n"+
+		"The existing keys with prefix "+ddlOwnerKeyPrefix+":\n  "+ownerKey+"\n")
+
+	backupDir := c.MkDir()
+	args = append([]string{"etcd", "delowner", "0x694D7A6A8C3BD404", "--yes", "--backup", filepath.Join(backupDir, "owner.json")}, pdArgs...)
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Matches, "(?s)current key-values:\n  "+ownerKey+" = .*verified that "+ownerKey+" is deleted\n")
+
+	args = append([]string{"etcd", "delschema", "a4e3b4e1-6e3a-4c1b-9c6e-2f1c6e0b7d1e", "--yes", "--backup", filepath.Join(backupDir, "schema.json")}, pdArgs...)
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Matches, "(?s)current key-values:\n  "+schemaKey+" = 20 .*verified that "+schemaKey+" is deleted\n")
+	c.Assert(kvs, HasLen, 0)
+}
+
+func (s *etcdTestSuite) TestRestoreLeasedKey(c *C) {
+	defer func() { etcdForce = false }()
+	ownerKey := ddlOwnerKeyPrefix + "694d7a6a8c3bd404"
+	ownerValue := "a4e3b4e1-6e3a-4c1b-9c6e-2f1c6e0b7d1e"
+	f := &fakeEtcd{
+		c:      c,
+		kvs:    map[string]string{ownerKey: ownerValue},
+		leases: map[string]string{ownerKey: "7587848943239472132"},
+		ttls:   map[string]int64{"7587848943239472132": 10},
+	}
+	ts, pdArgs := serveFakeEtcd(c, f)
+	defer ts.Close()
+	cmd := initCommand()
+	backup := filepath.Join(c.MkDir(), "owner.json")
+	args := append([]string{"etcd", "delowner", "0x694D7A6A8C3BD404", "--yes", "--backup", backup}, pdArgs...)
+	_, _, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(f.kvs, HasLen, 0)
+	data, err := ioutil.ReadFile(backup)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Matches, `(?s).*"lease": "7587848943239472132".*`)
+
+	// The key is put back with its lease while the lease is alive.
+	args = append([]string{"etcd", "restore", backup, "--yes"}, pdArgs...)
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Matches, "(?s).*\n  "+ownerKey+" = "+ownerValue+" \\(lease: 7587848943239472132\\)\nrestored 1 key-values\n")
+	c.Assert(f.kvs, DeepEquals, map[string]string{ownerKey: ownerValue})
+	c.Assert(f.leases, DeepEquals, map[string]string{ownerKey: "7587848943239472132"})
+
+	// The key whose lease has expired is not restored as a permanent key.
+	delete(f.kvs, ownerKey)
+	delete(f.leases, ownerKey)
+	delete(f.ttls, "7587848943239472132")
+	_, _, err = executeCommandC(cmd, args...)
+	c.Assert(err, ErrorMatches, "the leases of the keys have expired, they would never expire if they were put back, "+
+		"use --force to put them without lease: "+ownerKey+" \\(lease: 7587848943239472132\\)")
+	c.Assert(f.kvs, HasLen, 0)
+
+	args = append(args, "--force")
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Matches, "(?s).*\n  "+ownerKey+" = "+ownerValue+"\nrestored 1 key-values\n")
+	c.Assert(f.kvs, DeepEquals, map[string]string{ownerKey: ownerValue})
+	c.Assert(f.leases, HasLen, 0)
+}
+
+func (s *etcdTestSuite) TestPutKey(c *C) {
 	testKey := "test"
+	testValue := "test"
+	kvs := map[string]string{}
+	ts, pdArgs := newFakeEtcdServer(c, kvs)
+	defer ts.Close()
+	cmd := initCommand()
+	args := append([]string{"etcd", "putkey", testKey, testValue, "--yes"}, pdArgs...)
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "current key-values:\n  (none)\nwill put "+ddlAllSchemaVersionsPrefix+testKey+" = test\nput key successfully\n")
+	c.Assert(kvs, DeepEquals, map[string]string{ddlAllSchemaVersionsPrefix + testKey: testValue})
+}
+
+func (s *etcdTestSuite) TestGetAndList(c *C) {
+	var para parameter
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.URL.EscapedPath(), Equals, rangeQueryPrefix)
+		para = parameter{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&para), IsNil)
 		w.WriteHeader(http.StatusOK)
-		c.Assert(r.Method, Equals, http.MethodPost)
-		c.Assert(r.URL.EscapedPath(), Equals, rangeDelPrefix)
-		result, err := ioutil.ReadAll(r.Body)
-		c.Assert(err, IsNil)
-		var resp parameter
-		err = json.Unmarshal(result, &resp)
+		_, err := w.Write([]byte(`{"header":{"revision":"10"},"kvs":[{"key":"` + base64Encode("/tidb/ddl/fg/owner/1") +
+			`","value":"` + base64Encode("owner") + `","mod_revision":"5"}],"count":"2","more":true}`))
 		c.Assert(err, IsNil)
-		c.Assert(resp.Key, Equals, base64Encode(testKey))
-		c.Assert(resp.RangeEnd, Equals, "")
 	}))
 	defer ts.Close()
 	u, err := url.Parse(ts.URL)
 	c.Assert(err, IsNil)
 	uArr := strings.Split(u.Host, ":")
 	cmd := initCommand()
-	args := []string{"etcd", "delkey", testKey, "-i", uArr[0], "-p", uArr[1]}
+	args := []string{"etcd", "get", "/tidb/ddl/", "--prefix", "--limit", "1", "--rev", "8", "-i", uArr[0], "-p", uArr[1]}
 	_, output, err := executeCommandC(cmd, args...)
 	c.Assert(err, IsNil)
-	c.Assert(string(output), Equals, "This function only for delete the key-value about DDL\n")
+	c.Assert(para, DeepEquals, parameter{Key: base64Encode("/tidb/ddl/"), RangeEnd: base64Encode("/tidb/ddl0"), Limit: 1, Revision: 8})
+	c.Assert(string(output), Matches, `(?s).*"key": "/tidb/ddl/fg/owner/1".*"value": "owner".*"more": true.*`)
 
-	testKey = "/tidb/ddl/12345"
-	args = []string{"etcd", "delkey", testKey, "-i", uArr[0], "-p", uArr[1]}
-	_, output, err = executeCommandC(cmd, args...)
+	args = []string{"etcd", "ls", "--prefix", "/tidb/ddl/fg/owner/", "--limit", "0", "--rev", "0", "-i", uArr[0], "-p", uArr[1]}
+	_, _, err = executeCommandC(cmd, args...)
 	c.Assert(err, IsNil)
-	c.Assert(output, NotNil)
+	c.Assert(para, DeepEquals, parameter{Key: base64Encode("/tidb/ddl/fg/owner/"), RangeEnd: base64Encode("/tidb/ddl/fg/owner0"), KeysOnly: true})
 }
 
-func (s *etcdTestSuite) TestPutKey(c *C) {
-	testKey := "test"
-	testValue := "test"
+func (s *etcdTestSuite) TestPrefixRangeEnd(c *C) {
+	c.Assert(prefixRangeEnd("/tidb/ddl"), Equals, "/tidb/ddm")
+	c.Assert(prefixRangeEnd("a\xff"), Equals, "b")
+	c.Assert(prefixRangeEnd("\xff\xff"), Equals, "\x00")
+	c.Assert(prefixRangeEnd(""), Equals, "\x00")
+}
+
+func (s *etcdTestSuite) TestWatch(c *C) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.URL.EscapedPath(), Equals, watchPrefix)
+		var req struct {
+			CreateRequest struct {
+				Key      string `json:"key"`
+				RangeEnd string `json:"range_end"`
+			} `json:"create_request"`
+		}
+		c.Assert(json.NewDecoder(r.Body).Decode(&req), IsNil)
+		c.Assert(req.CreateRequest.Key, Equals, base64Encode("/tidb/ddl"))
+		c.Assert(req.CreateRequest.RangeEnd, Equals, base64Encode("/tidb/ddm"))
 		w.WriteHeader(http.StatusOK)
-		c.Assert(r.Method, Equals, http.MethodPost)
-		c.Assert(r.URL.EscapedPath(), Equals, putPrefix)
-		result, err := ioutil.ReadAll(r.Body)
+		_, err := w.Write([]byte(`{"result":{"header":{"revision":"7"},"created":true}}
+{"result":{"header":{"revision":"8"},"events":[{"kv":{"key":"` + base64Encode("/tidb/ddl/fg/owner/1") + `","value":"` + base64Encode("a") + `","mod_revision":"8"}},` +
+			`{"type":"DELETE","kv":{"key":"` + base64Encode("/tidb/ddl/fg/owner/0") + `","mod_revision":"8"}}]}}
+`))
 		c.Assert(err, IsNil)
-		var resp struct {
-			Key   string `json:"key"`
-			Value string `json:"value"`
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	c.Assert(err, IsNil)
+	uArr := strings.Split(u.Host, ":")
+	cmd := initCommand()
+	args := []string{"etcd", "watch", "-i", uArr[0], "-p", uArr[1]}
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Matches, `\S+ \S+ watching /tidb/ddl from revision 7
+\S+ \S+ \[rev 8\] PUT /tidb/ddl/fg/owner/1 = a
+\S+ \S+ \[rev 8\] DELETE /tidb/ddl/fg/owner/0
+watch closed
+`)
+}
+
+func (s *etcdTestSuite) TestAuthentication(c *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp string
+		switch r.URL.EscapedPath() {
+		case authPrefix:
+			var req struct {
+				Name     string `json:"name"`
+				Password string `json:"password"`
+			}
+			c.Assert(json.NewDecoder(r.Body).Decode(&req), IsNil)
+			if req.Name != "root" || req.Password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, err := w.Write([]byte(`{"error":"authentication failed, invalid user ID or password"}`))
+				c.Assert(err, IsNil)
+				return
+			}
+			resp = `{"header":{},"token":"abc.123"}`
+		case rangeQueryPrefix:
+			c.Assert(r.Header.Get("Authorization"), Equals, "abc.123")
+			resp = `{"header":{},"kvs":[]}`
+		default:
+			c.Fatalf("unexpected path %s", r.URL.EscapedPath())
 		}
-		err = json.Unmarshal(result, &resp)
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(resp))
 		c.Assert(err, IsNil)
-		c.Assert(resp.Key, Equals, base64Encode(ddlAllSchemaVersionsPrefix+testKey))
-		c.Assert(resp.Value, Equals, base64Encode(testValue))
 	}))
 	defer ts.Close()
 	u, err := url.Parse(ts.URL)
 	c.Assert(err, IsNil)
 	uArr := strings.Split(u.Host, ":")
 	cmd := initCommand()
-	args := []string{"etcd", "putkey", testKey, testValue, "-i", uArr[0], "-p", uArr[1]}
-	_, output, err := executeCommandC(cmd, args...)
+
+	passwordFile := filepath.Join(c.MkDir(), "password")
+	c.Assert(ioutil.WriteFile(passwordFile, []byte("secret\n"), 0600), IsNil)
+	args := []string{"etcd", "ls", "--etcd-user", "root", "--etcd-password-file", passwordFile, "-i", uArr[0], "-p", uArr[1]}
+	_, _, err = executeCommandC(cmd, args...)
 	c.Assert(err, IsNil)
-	c.Assert(output, NotNil)
+
+	// The password is prompted if the password file is not set.
+	cmd.SetIn(strings.NewReader("wrong\n"))
+	args = []string{"etcd", "ls", "--etcd-user", "root", "--etcd-password-file", "", "-i", uArr[0], "-p", uArr[1]}
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, ErrorMatches, "failed to authenticate etcd user root: HTTP 401 Unauthorized from .*/v3/auth/authenticate: .*invalid user ID or password.*")
+	c.Assert(string(output), Matches, "Password of etcd user root: (?s).*")
 }
@@ -0,0 +1,322 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/spf13/cobra"
+)
+
+// mutating etcd command flags
+var (
+	etcdYes        bool
+	etcdDryRun     bool
+	etcdBackupPath string
+	etcdForce      bool
+)
+
+const leaseTimeToLivePrefix = "/v3/lease/timetolive"
+
+// etcdKV is a key-value of etcd, the key and value are base64 encoded in JSON as grpc_gateway does.
+type etcdKV struct {
+	Key         []byte `json:"key"`
+	Value       []byte `json:"value"`
+	ModRevision string `json:"mod_revision,omitempty"`
+	// Lease is the ID of the lease attached to the key, the key is deleted when the lease expires.
+	Lease string `json:"lease,omitempty"`
+}
+
+func (kv *etcdKV) hasLease() bool {
+	return len(kv.Lease) != 0 && kv.Lease != "0"
+}
+
+// etcdBackup is the key-values saved before they are deleted or overwritten.
+type etcdBackup struct {
+	PD   string    `json:"pd"`
+	Time time.Time `json:"time"`
+	Kvs  []*etcdKV `json:"kvs"`
+}
+
+// addMutationFlags adds the flags of the commands which change key-values.
+func addMutationFlags(m *cobra.Command) {
+	m.Flags().BoolVarP(&etcdYes, "yes", "y", false, "do not ask for confirmation")
+	m.Flags().BoolVar(&etcdDryRun, "dry-run", false, "only show what will be changed")
+	m.Flags().StringVar(&etcdBackupPath, "backup", "", "the backup file of the changed key-values, tidb-ctl-etcd-backup.[time].json by default")
+}
+
+// newRestoreCommand returns a restore subcommand of EtcdCommand.
+func newRestoreCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "restore",
+		Short: "Put back the key-values saved by delkey, putkey, delowner or delschema by `restore [backup file]`",
+		RunE:  restoreCommandFunc,
+	}
+	addMutationFlags(m)
+	m.Flags().BoolVar(&etcdForce, "force", false,
+		"restore the backup of another PD, and put the keys whose leases have expired without lease, they will never expire")
+	return m
+}
+
+func restoreCommandFunc(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("only one argument, the backup file, is expected")
+	}
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var backup etcdBackup
+	if err = json.Unmarshal(data, &backup); err != nil {
+		return errors.Errorf("invalid backup file %s: %v", args[0], err)
+	}
+	if len(backup.Kvs) == 0 {
+		cmd.Println("Nothing to restore.")
+		return nil
+	}
+	pd := currentPD()
+	if backup.PD != pd && !etcdForce {
+		return errors.Errorf("the backup is saved from PD %s instead of %s, use --force to restore it anyway", backup.PD, pd)
+	}
+	// The keys with leases, such as the DDL owner key, must not be put back as permanent keys after the leases expire.
+	var expired []string
+	for _, kv := range backup.Kvs {
+		if !kv.hasLease() {
+			continue
+		}
+		alive, err := isLeaseAlive(kv.Lease)
+		if err != nil {
+			return err
+		}
+		if !alive {
+			expired = append(expired, fmt.Sprintf("%s (lease: %s)", kv.Key, kv.Lease))
+			if etcdForce {
+				kv.Lease = ""
+			}
+		}
+	}
+	if len(expired) > 0 && !etcdForce {
+		return errors.Errorf("the leases of the keys have expired, they would never expire if they were put back, "+
+			"use --force to put them without lease: %s", strings.Join(expired, ", "))
+	}
+	var current []*etcdKV
+	for _, kv := range backup.Kvs {
+		kvs, err := getKVs(newRangeParameter(string(kv.Key), ""))
+		if err != nil {
+			return err
+		}
+		current = append(current, kvs...)
+	}
+	var desc strings.Builder
+	desc.WriteString(fmt.Sprintf("will put %d key-values saved from PD %s at %s into PD %s:",
+		len(backup.Kvs), backup.PD, backup.Time.Format(time.RFC3339), pd))
+	for _, kv := range backup.Kvs {
+		desc.WriteString(fmt.Sprintf("\n  %s = %s", kv.Key, kv.Value))
+		if kv.hasLease() {
+			desc.WriteString(fmt.Sprintf(" (lease: %s)", kv.Lease))
+		}
+	}
+	done, err := runMutation(cmd, current, desc.String(), func() error {
+		for _, kv := range backup.Kvs {
+			if err := putKV(kv.Key, kv.Value, kv.Lease); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if done {
+		cmd.Printf("restored %d key-values\n", len(backup.Kvs))
+	}
+	return nil
+}
+
+// currentPD returns the address of PD server in the form of the backups.
+func currentPD() string {
+	return net.JoinHostPort(pdHost.String(), strconv.Itoa(int(pdPort)))
+}
+
+// runMutation shows the current key-values and what will be changed, then asks for confirmation,
+// backs up the current key-values and applies the change. It returns whether the change is applied.
+func runMutation(cmd *cobra.Command, current []*etcdKV, desc string, apply func() error) (bool, error) {
+	cmd.Println("current key-values:")
+	if len(current) == 0 {
+		cmd.Println("  (none)")
+	}
+	for _, kv := range current {
+		cmd.Printf("  %s = %s (mod_revision: %s)\n", kv.Key, kv.Value, kv.ModRevision)
+	}
+	cmd.Println(desc)
+	if etcdDryRun {
+		cmd.Println("dry run, nothing is changed")
+		return false, nil
+	}
+	if !etcdYes {
+		ok, err := confirm(cmd, "Are you sure to continue?")
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			cmd.Println("aborted")
+			return false, nil
+		}
+	}
+	if len(current) > 0 {
+		path, err := writeEtcdBackup(current)
+		if err != nil {
+			return false, err
+		}
+		cmd.Printf("the current key-values are saved to %s, use `tidb-ctl etcd restore %s` to put them back\n", path, path)
+	}
+	return true, apply()
+}
+
+// confirm asks the user to answer yes or no.
+func confirm(cmd *cobra.Command, prompt string) (bool, error) {
+	cmd.Printf("%s [y/N]: ", prompt)
+	answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+func writeEtcdBackup(kvs []*etcdKV) (string, error) {
+	backup := &etcdBackup{
+		PD:   currentPD(),
+		Time: time.Now(),
+		Kvs:  kvs,
+	}
+	data, err := json.MarshalIndent(backup, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	path := etcdBackupPath
+	if len(path) == 0 {
+		path = fmt.Sprintf("tidb-ctl-etcd-backup.%s.json", backup.Time.Format("2006-01-02.15.04.05"))
+	}
+	return path, ioutil.WriteFile(path, data, 0644)
+}
+
+// getKVs gets the key-values of the range parameter.
+func getKVs(para *parameter) ([]*etcdKV, error) {
+	res, err := getRange(para)
+	if err != nil {
+		return nil, err
+	}
+	var rangeResponse struct {
+		Kvs []*etcdKV `json:"kvs"`
+	}
+	if err = json.Unmarshal([]byte(res), &rangeResponse); err != nil {
+		return nil, err
+	}
+	return rangeResponse.Kvs, nil
+}
+
+// deleteKey deletes the key and returns the number of deleted keys.
+func deleteKey(key string) (int64, error) {
+	reqData, err := json.Marshal(newRangeParameter(key, ""))
+	if err != nil {
+		return 0, err
+	}
+	req, err := getRequest(rangeDelPrefix, http.MethodPost, "application/json", bytes.NewBuffer(reqData))
+	if err != nil {
+		return 0, err
+	}
+	res, err := dial(req)
+	if err != nil {
+		return 0, err
+	}
+	var delResponse struct {
+		Deleted string `json:"deleted"`
+	}
+	if err = json.Unmarshal([]byte(res), &delResponse); err != nil {
+		return 0, err
+	}
+	if len(delResponse.Deleted) == 0 {
+		return 0, nil
+	}
+	deleted, err := strconv.ParseInt(delResponse.Deleted, 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid delete response %s", res)
+	}
+	return deleted, nil
+}
+
+// putKV puts the key-value with the lease, the key never expires if the lease is empty.
+func putKV(key, value []byte, lease string) error {
+	var putParameter struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+		Lease string `json:"lease,omitempty"`
+	}
+	putParameter.Key = base64Encode(string(key))
+	putParameter.Value = base64Encode(string(value))
+	putParameter.Lease = lease
+	reqData, err := json.Marshal(putParameter)
+	if err != nil {
+		return err
+	}
+	req, err := getRequest(putPrefix, http.MethodPost, "application/json", bytes.NewBuffer(reqData))
+	if err != nil {
+		return err
+	}
+	_, err = dial(req)
+	return err
+}
+
+// isLeaseAlive returns whether the lease has not expired.
+func isLeaseAlive(lease string) (bool, error) {
+	reqData, err := json.Marshal(map[string]string{"ID": lease})
+	if err != nil {
+		return false, err
+	}
+	req, err := getRequest(leaseTimeToLivePrefix, http.MethodPost, "application/json", bytes.NewBuffer(reqData))
+	if err != nil {
+		return false, err
+	}
+	res, err := dial(req)
+	if err != nil {
+		return false, err
+	}
+	// The TTL is -1 if the lease has expired or is not found.
+	var ttlResponse struct {
+		TTL string `json:"TTL"`
+	}
+	if err = json.Unmarshal([]byte(res), &ttlResponse); err != nil {
+		return false, err
+	}
+	if len(ttlResponse.TTL) == 0 {
+		return false, nil
+	}
+	ttl, err := strconv.ParseInt(ttlResponse.TTL, 10, 64)
+	if err != nil {
+		return false, errors.Errorf("invalid lease response %s", res)
+	}
+	return ttl > 0, nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/spf13/cobra"
+)
+
+var watchPrefix = "/v3/watch"
+
+// etcd watch command flags
+var (
+	etcdWatchPrefix   string
+	etcdWatchRevision int64
+)
+
+// watchResponse is a message in the stream of the watch API of grpc_gateway.
+type watchResponse struct {
+	Result *struct {
+		Header struct {
+			Revision string `json:"revision"`
+		} `json:"header"`
+		Created         bool         `json:"created"`
+		Canceled        bool         `json:"canceled"`
+		CompactRevision string       `json:"compact_revision"`
+		CancelReason    string       `json:"cancel_reason"`
+		Events          []watchEvent `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type watchEvent struct {
+	// Type is omitted for PUT events since PUT is the zero value.
+	Type string `json:"type"`
+	Kv   struct {
+		Key         []byte `json:"key"`
+		Value       []byte `json:"value"`
+		ModRevision string `json:"mod_revision"`
+	} `json:"kv"`
+}
+
+// newWatchCommand returns a watch subcommand of EtcdCommand.
+func newWatchCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "watch",
+		Short: "Watch the changes of the keys with the prefix by `watch --prefix [prefix]` until interrupted",
+		Run:   watchCommandFunc,
+	}
+	m.Flags().StringVar(&etcdWatchPrefix, "prefix", "/tidb/ddl", "the prefix of keys")
+	m.Flags().Int64Var(&etcdWatchRevision, "rev", 0, "the revision to watch from, 0 means the latest revision")
+	return m
+}
+
+func watchCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Println("No argument is needed, use --prefix instead!")
+		return
+	}
+	if err := watchPrefixChanges(cmd, etcdWatchPrefix, etcdWatchRevision); err != nil {
+		cmd.Printf("Failed to watch: %v\n", err)
+	}
+}
+
+// watchPrefixChanges prints the events of the keys with the prefix until the stream is closed.
+func watchPrefixChanges(cmd *cobra.Command, prefix string, startRevision int64) error {
+	var watchParameter struct {
+		CreateRequest struct {
+			Key           string `json:"key"`
+			RangeEnd      string `json:"range_end"`
+			StartRevision int64  `json:"start_revision,omitempty"`
+		} `json:"create_request"`
+	}
+	watchParameter.CreateRequest.Key = base64Encode(prefix)
+	watchParameter.CreateRequest.RangeEnd = base64Encode(prefixRangeEnd(prefix))
+	watchParameter.CreateRequest.StartRevision = startRevision
+
+	reqData, err := json.Marshal(watchParameter)
+	if err != nil {
+		return err
+	}
+	req, err := getRequest(watchPrefix, http.MethodPost, "application/json", bytes.NewBuffer(reqData))
+	if err != nil {
+		return err
+	}
+	resp, err := pdClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := resp.Body.Close()
+		if closeErr != nil {
+			fmt.Printf("response close error: %v", closeErr)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return genResponseError(resp)
+	}
+
+	// The response is a stream of JSON messages, one for each watch response.
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var wr watchResponse
+		if err = decoder.Decode(&wr); err != nil {
+			if err == io.EOF {
+				cmd.Println("watch closed")
+				return nil
+			}
+			return err
+		}
+		if wr.Error != nil {
+			return errors.New(wr.Error.Message)
+		}
+		if wr.Result == nil {
+			continue
+		}
+		now := time.Now().Format("2006-01-02 15:04:05.000")
+		switch {
+		case wr.Result.Created:
+			cmd.Printf("%s watching %s from revision %s\n", now, prefix, wr.Result.Header.Revision)
+		case wr.Result.Canceled:
+			if len(wr.Result.CompactRevision) != 0 && wr.Result.CompactRevision != "0" {
+				return errors.Errorf("watch canceled, the revision has been compacted to %s", wr.Result.CompactRevision)
+			}
+			return errors.Errorf("watch canceled: %s", wr.Result.CancelReason)
+		}
+		for _, ev := range wr.Result.Events {
+			if ev.Type == "DELETE" {
+				cmd.Printf("%s [rev %s] DELETE %s\n", now, ev.Kv.ModRevision, ev.Kv.Key)
+				continue
+			}
+			cmd.Printf("%s [rev %s] PUT %s = %s\n", now, ev.Kv.ModRevision, ev.Kv.Key, ev.Kv.Value)
+		}
+	}
+}
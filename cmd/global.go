@@ -15,6 +15,10 @@ package cmd
 
 import (
 	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
 )
 
 func base64Encode(str string) string {
@@ -28,3 +32,39 @@ func base64Decode(str string) (string, error) {
 	}
 	return string(data), nil
 }
+
+// humanizeBytes returns a human-readable form of a size in bytes, such as 1.5 MiB.
+func humanizeBytes(size float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	i := 0
+	for ; size >= 1024 && i < len(units)-1; i++ {
+		size /= 1024
+	}
+	if i == 0 {
+		return strconv.FormatFloat(size, 'f', 0, 64) + " " + units[i]
+	}
+	return strconv.FormatFloat(size, 'f', 1, 64) + " " + units[i]
+}
+
+// printTable prints the rows in aligned columns.
+func printTable(c *cobra.Command, header []string, rows [][]string) {
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	for _, row := range append([][]string{header}, rows...) {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			if i == len(row)-1 {
+				cells[i] = cell
+				continue
+			}
+			cells[i] = cell + strings.Repeat(" ", widths[i]-len(cell))
+		}
+		c.Println(strings.Join(cells, "  "))
+	}
+}
@@ -26,3 +26,11 @@ func (s *Global) TestBase64Decode(c *C) {
 	c.Assert(err, ErrorMatches, "*illegal.*")
 
 }
+
+func (s *Global) TestHumanizeBytes(c *C) {
+	c.Parallel()
+	c.Assert(humanizeBytes(0), Equals, "0 B")
+	c.Assert(humanizeBytes(1023), Equals, "1023 B")
+	c.Assert(humanizeBytes(1536), Equals, "1.5 KiB")
+	c.Assert(humanizeBytes(3*1024*1024*1024), Equals, "3.0 GiB")
+}
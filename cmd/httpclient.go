@@ -0,0 +1,251 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pingcap/errors"
+)
+
+// http client flags
+var (
+	httpTimeout time.Duration
+	httpRetries int
+)
+
+// httpRetryBackoff is the backoff before the first retry, it is doubled for each retry up to httpMaxRetryBackoff.
+var (
+	httpRetryBackoff    = 500 * time.Millisecond
+	httpMaxRetryBackoff = 5 * time.Second
+)
+
+const (
+	timeoutFlagName = "timeout"
+	retriesFlagName = "retries"
+)
+
+// newHTTPClient returns the client of TiDB and PD, the proxy is taken from HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+// The timeout is applied to connecting, the TLS handshake and waiting for the response header of each attempt,
+// so that the streaming responses such as etcd watch are not interrupted, the other requests should be sent
+// by withTimeout to bound reading the body too.
+func newHTTPClient(tlsConfig *tls.Config) *http.Client {
+	dialer := &net.Dialer{Timeout: httpTimeout, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		Transport: &retryTransport{
+			base: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           dialer.DialContext,
+				TLSClientConfig:       tlsConfig,
+				TLSHandshakeTimeout:   httpTimeout,
+				ResponseHeaderTimeout: httpTimeout,
+			},
+			retries: httpRetries,
+		},
+	}
+}
+
+// timeoutKey marks the requests whose attempts are limited by --timeout as a whole.
+type timeoutKey struct{}
+
+// withTimeout returns the request whose every attempt has a deadline of --timeout, including reading the body
+// of the last attempt, so that a stalled attempt is retried. It must not be used for the streaming requests.
+func withTimeout(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), timeoutKey{}, true))
+}
+
+// cancelBody cancels the context of the attempt after the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryTransport retries the idempotent GET requests which fail on the network or get a gateway error,
+// and describes the network errors by where they fail.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := t.retries
+	if !isIdempotent(req) {
+		retries = 0
+	}
+	backoff := httpRetryBackoff
+	for i := 0; ; i++ {
+		attempt, cancel := req, context.CancelFunc(func() {})
+		if limited, _ := req.Context().Value(timeoutKey{}).(bool); limited && httpTimeout > 0 {
+			var ctx context.Context
+			ctx, cancel = context.WithTimeout(req.Context(), httpTimeout)
+			attempt = req.WithContext(ctx)
+		}
+		resp, err := t.base.RoundTrip(attempt)
+		retryable := false
+		if err != nil {
+			err = newRequestError(req, err)
+			if reqErr, ok := err.(*requestError); ok {
+				retryable = reqErr.retryable
+			}
+		} else if isGatewayError(resp.StatusCode) {
+			retryable = true
+		}
+		if !retryable || i >= retries {
+			if resp == nil {
+				cancel()
+				return nil, err
+			}
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, err
+		}
+		if resp != nil {
+			// Drain the body so that the connection can be reused.
+			_, copyErr := io.Copy(ioutil.Discard, resp.Body)
+			closeErr := resp.Body.Close()
+			cancel()
+			if copyErr != nil {
+				return nil, copyErr
+			}
+			if closeErr != nil {
+				return nil, closeErr
+			}
+		} else {
+			cancel()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > httpMaxRetryBackoff {
+			backoff = httpMaxRetryBackoff
+		}
+	}
+}
+
+// isIdempotent returns whether the request can be sent again safely.
+func isIdempotent(req *http.Request) bool {
+	return (req.Method == http.MethodGet || req.Method == http.MethodHead) && (req.Body == nil || req.Body == http.NoBody)
+}
+
+func isGatewayError(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// requestError is a network error of a request, the message tells whether it fails on DNS, connecting or TLS.
+type requestError struct {
+	msg       string
+	err       error
+	retryable bool
+}
+
+func (e *requestError) Error() string {
+	return e.msg + ": " + e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// newRequestError describes the error of the request by where it fails, only the failures of DNS, connecting
+// and timeouts are retryable.
+func newRequestError(req *http.Request, err error) error {
+	if findError(err, func(e error) bool { return e == context.Canceled }) != nil {
+		return err
+	}
+	addr := req.URL.Host
+	if dnsErr, ok := findError(err, func(e error) bool {
+		_, ok := e.(*net.DNSError)
+		return ok
+	}).(*net.DNSError); ok {
+		return &requestError{msg: fmt.Sprintf("cannot resolve the host of %s", addr), err: err, retryable: dnsErr.Temporary() || dnsErr.Timeout()}
+	}
+	if findError(err, isCertificateError) != nil {
+		return &requestError{msg: fmt.Sprintf("cannot verify the TLS certificate of %s", addr), err: err}
+	}
+	if findError(err, isTLSHandshakeError) != nil {
+		return &requestError{msg: fmt.Sprintf("TLS handshake with %s failed", addr), err: err}
+	}
+	if findError(err, func(e error) bool {
+		netErr, ok := e.(net.Error)
+		return ok && netErr.Timeout()
+	}) != nil {
+		return &requestError{msg: fmt.Sprintf("request to %s timed out", addr), err: err, retryable: true}
+	}
+	if findError(err, func(e error) bool {
+		opErr, ok := e.(*net.OpError)
+		return ok && opErr.Op == "dial"
+	}) != nil {
+		return &requestError{msg: fmt.Sprintf("cannot connect to %s", addr), err: err, retryable: true}
+	}
+	return &requestError{msg: fmt.Sprintf("request to %s failed", addr), err: err}
+}
+
+func isCertificateError(err error) bool {
+	switch err.(type) {
+	case x509.UnknownAuthorityError, x509.HostnameError, x509.CertificateInvalidError:
+		return true
+	}
+	return false
+}
+
+// isTLSHandshakeError returns whether the error is a record which is not TLS, or an alert sent by the server.
+func isTLSHandshakeError(err error) bool {
+	switch e := err.(type) {
+	case tls.RecordHeaderError:
+		return true
+	case *net.OpError:
+		return e.Op == "remote error"
+	}
+	return false
+}
+
+// findError returns the first error passing the test in the chain unwrapped by Cause or Unwrap, nil is returned if it is not found.
+func findError(err error, test func(error) bool) error {
+	for err != nil {
+		if test(err) {
+			return err
+		}
+		if cause := errors.Unwrap(err); cause != nil {
+			err = cause
+			continue
+		}
+		wrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil
+		}
+		err = wrapper.Unwrap()
+	}
+	return nil
+}
+
+// httpStatusError is the error of an HTTP response whose status is not OK.
+func httpStatusError(url string, status int, body []byte) error {
+	return errors.Errorf("HTTP %d %s from %s: %s", status, http.StatusText(status), url, bytes.TrimSpace(body))
+}
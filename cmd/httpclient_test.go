@@ -0,0 +1,266 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+)
+
+var _ = Suite(&httpClientTestSuite{})
+
+type httpClientTestSuite struct {
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+}
+
+func (s *httpClientTestSuite) SetUpTest(c *C) {
+	s.timeout, s.retries, s.backoff = httpTimeout, httpRetries, httpRetryBackoff
+	httpTimeout, httpRetries, httpRetryBackoff = time.Second, 2, time.Millisecond
+}
+
+func (s *httpClientTestSuite) TearDownTest(c *C) {
+	httpTimeout, httpRetries, httpRetryBackoff = s.timeout, s.retries, s.backoff
+}
+
+func (s *httpClientTestSuite) TestRetry(c *C) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, err := w.Write([]byte("ok"))
+		c.Assert(err, IsNil)
+	}))
+	defer ts.Close()
+	client := newHTTPClient(nil)
+
+	resp, err := client.Get(ts.URL)
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(resp.Body.Close(), IsNil)
+	c.Assert(atomic.LoadInt32(&requests), Equals, int32(3))
+
+	// The requests with a body are not retried.
+	resp, err = client.Post(ts.URL, "application/json", strings.NewReader("{}"))
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusServiceUnavailable)
+	c.Assert(resp.Body.Close(), IsNil)
+	c.Assert(atomic.LoadInt32(&requests), Equals, int32(4))
+
+	httpRetries = 0
+	resp, err = newHTTPClient(nil).Get(ts.URL)
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusServiceUnavailable)
+	c.Assert(resp.Body.Close(), IsNil)
+	c.Assert(atomic.LoadInt32(&requests), Equals, int32(5))
+}
+
+func (s *httpClientTestSuite) TestRequestErrors(c *C) {
+	client := newHTTPClient(nil)
+
+	// Nothing listens on the closed port.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	addr := listener.Addr().String()
+	c.Assert(listener.Close(), IsNil)
+	_, err = client.Get("http://" + addr)
+	c.Assert(err, ErrorMatches, ".*cannot connect to "+addr+": .*")
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+	_, err = client.Get(tlsServer.URL)
+	c.Assert(err, ErrorMatches, ".*cannot verify the TLS certificate of "+tlsServer.Listener.Addr().String()+": .*")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, err = client.Get(strings.Replace(server.URL, "http://", "https://", 1))
+	c.Assert(err, ErrorMatches, ".*TLS handshake with "+server.Listener.Addr().String()+" failed: .*")
+
+	req, err := http.NewRequest(http.MethodGet, "http://tidb.invalid:10080", nil)
+	c.Assert(err, IsNil)
+	err = newRequestError(req, &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "tidb.invalid", IsNotFound: true}})
+	c.Assert(err, ErrorMatches, "cannot resolve the host of tidb.invalid:10080: dial tcp: lookup tidb.invalid.*: no such host")
+	c.Assert(err.(*requestError).retryable, IsFalse)
+
+	// The other errors are not retryable, and the errors are matched by their types instead of the messages.
+	err = newRequestError(req, &url.Error{Op: "Get", URL: req.URL.String(), Err: errors.New("tls: unexpected message")})
+	c.Assert(err, ErrorMatches, "request to tidb.invalid:10080 failed: .*")
+	c.Assert(err.(*requestError).retryable, IsFalse)
+	err = newRequestError(req, &url.Error{Op: "Get", URL: req.URL.String(), Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}})
+	c.Assert(err, ErrorMatches, "cannot connect to tidb.invalid:10080: .*")
+	c.Assert(err.(*requestError).retryable, IsTrue)
+
+	c.Assert(httpStatusError("http://127.0.0.1:10080/schema", http.StatusNotFound, []byte("not found\n")), ErrorMatches,
+		"HTTP 404 Not Found from http://127.0.0.1:10080/schema: not found")
+}
+
+func (s *httpClientTestSuite) TestBodyTimeout(c *C) {
+	defer func(ctlSchema string, ctl, pd *http.Client) {
+		schema, ctlClient, pdClient = ctlSchema, ctl, pd
+	}(schema, ctlClient, pdClient)
+	httpTimeout, httpRetries = 100*time.Millisecond, 0
+	schema, ctlClient, pdClient = "http", newHTTPClient(nil), newHTTPClient(nil)
+	// The server sends the header and stalls the body until the request is canceled.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte("{"))
+		c.Assert(err, IsNil)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	_, _, err := httpGetFrom(ts.Listener.Addr().String(), "schema")
+	c.Assert(err, ErrorMatches, ".*context deadline exceeded.*")
+	req, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader("{}"))
+	c.Assert(err, IsNil)
+	_, err = dial(req)
+	c.Assert(err, ErrorMatches, ".*context deadline exceeded.*")
+}
+
+func (s *httpClientTestSuite) TestRetryStalled(c *C) {
+	defer func(ctlSchema string, ctl *http.Client) {
+		schema, ctlClient = ctlSchema, ctl
+	}(schema, ctlClient)
+	httpTimeout, httpRetries = 100*time.Millisecond, 1
+	schema, ctlClient = "http", newHTTPClient(nil)
+	// The first attempt stalls until it is canceled, the retry succeeds.
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		_, err := w.Write([]byte("{}"))
+		c.Assert(err, IsNil)
+	}))
+	defer ts.Close()
+
+	body, status, err := httpGetFrom(ts.Listener.Addr().String(), "schema")
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, http.StatusOK)
+	c.Assert(string(body), Equals, "{}")
+	c.Assert(atomic.LoadInt32(&requests), Equals, int32(2))
+}
+
+func (s *httpClientTestSuite) TestTLSConfig(c *C) {
+	defer func(ctlSchema, pdHTTPSchema string, ctl, pd *http.Client) {
+		ca, sslCert, sslKey, tlsServerName, insecureSkipVerify, tlsMinVersion = "", "", "", "", false, ""
+		pdTLS, pdCA, pdSSLCert, pdSSLKey, pdTLSServerName = pdTLSAuto, "", "", "", ""
+		schema, pdSchema, ctlClient, pdClient = ctlSchema, pdHTTPSchema, ctl, pd
+	}(schema, pdSchema, ctlClient, pdClient)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	ts.StartTLS()
+	defer ts.Close()
+	caPath := filepath.Join(c.MkDir(), "ca.pem")
+	c.Assert(ioutil.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0644), IsNil)
+	get := func(client *http.Client) error {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	c.Assert(setupHTTPClients(), IsNil)
+	c.Assert(schema, Equals, "http")
+	c.Assert(pdSchema, Equals, "http")
+
+	// The certificate of httptest is valid for example.com and 127.0.0.1.
+	ca, tlsServerName = caPath, "example.com"
+	c.Assert(setupHTTPClients(), IsNil)
+	c.Assert(schema, Equals, "https")
+	c.Assert(get(ctlClient), IsNil)
+	// The CA of PD is the same as TiDB's if it is not set.
+	c.Assert(pdSchema, Equals, "https")
+	c.Assert(get(pdClient), IsNil)
+	pdTLSServerName = "pd.tidb.test"
+	c.Assert(setupHTTPClients(), IsNil)
+	c.Assert(get(ctlClient), IsNil)
+	c.Assert(get(pdClient), ErrorMatches, ".*cannot verify the TLS certificate of .*")
+	// The server name of TiDB is not used for PD.
+	tlsServerName, pdTLSServerName = "tidb.test", ""
+	c.Assert(setupHTTPClients(), IsNil)
+	c.Assert(get(ctlClient), ErrorMatches, ".*cannot verify the TLS certificate of .*")
+	c.Assert(get(pdClient), IsNil)
+
+	// TLS of PD can be turned off while TiDB uses TLS, or on without any TLS option.
+	tlsServerName, pdTLS = "example.com", pdTLSOff
+	c.Assert(setupHTTPClients(), IsNil)
+	c.Assert(schema, Equals, "https")
+	c.Assert(pdSchema, Equals, "http")
+	pdCA = caPath
+	c.Assert(setupHTTPClients(), ErrorMatches, "PD: the TLS options of PD server are set but --pd-tls is off")
+	ca, tlsServerName, pdCA, pdTLS = "", "", "", pdTLSOn
+	c.Assert(setupHTTPClients(), IsNil)
+	c.Assert(schema, Equals, "http")
+	c.Assert(pdSchema, Equals, "https")
+	pdTLS = "yes"
+	c.Assert(setupHTTPClients(), ErrorMatches, "invalid --pd-tls yes, expect one of auto, on and off")
+
+	// --insecure-skip-verify and --tls-min-version modify the TLS config without turning TLS on.
+	pdTLS, insecureSkipVerify = pdTLSOn, true
+	c.Assert(setupHTTPClients(), IsNil)
+	c.Assert(schema, Equals, "http")
+	c.Assert(get(pdClient), IsNil)
+	ca, tlsServerName, pdTLS = caPath, "tidb.test", pdTLSAuto
+	c.Assert(setupHTTPClients(), IsNil)
+	c.Assert(get(ctlClient), IsNil)
+	tlsMinVersion = "1.3"
+	c.Assert(setupHTTPClients(), IsNil)
+	c.Assert(get(ctlClient), ErrorMatches, ".*TLS handshake with .* failed: .*")
+	c.Assert(get(pdClient), ErrorMatches, ".*TLS handshake with .* failed: .*")
+	ca, tlsServerName, tlsMinVersion = "", "", "1.2"
+	c.Assert(setupHTTPClients(), IsNil)
+	c.Assert(schema, Equals, "http")
+	c.Assert(pdSchema, Equals, "http")
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+	for _, client := range []*http.Client{ctlClient, pdClient} {
+		resp, err := client.Get(schema + "://" + plain.Listener.Addr().String())
+		c.Assert(err, IsNil)
+		c.Assert(resp.Body.Close(), IsNil)
+	}
+
+	// The setup failures are errors.
+	tlsMinVersion = "1.4"
+	c.Assert(setupHTTPClients(), ErrorMatches, "invalid TLS version 1.4, .*")
+	// The commands fail without running.
+	cmd := initCommand()
+	for _, args := range [][]string{{"base64decode", "AAAAACqPhb0="}, {"etcd", "ddlinfo"}} {
+		_, _, err := executeCommandC(cmd, args...)
+		c.Assert(err, ErrorMatches, "cannot setup tls: invalid TLS version 1.4, .*")
+	}
+	tlsMinVersion, insecureSkipVerify = "", false
+	pdCA = filepath.Join(c.MkDir(), "missing.pem")
+	c.Assert(setupHTTPClients(), ErrorMatches, "PD: could not read ca certificate: .*")
+	pdCA, sslCert = "", caPath
+	c.Assert(setupHTTPClients(), ErrorMatches, "the TLS cert and key should be set together")
+}
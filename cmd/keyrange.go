@@ -16,9 +16,7 @@ package cmd
 import (
 	"encoding/binary"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
-	"net/http"
 
 	"github.com/spf13/cobra"
 )
@@ -51,41 +49,17 @@ func showKeyRanges(_ *cobra.Command, args []string) error {
 		return nil
 	}
 
-	body, status, err := httpGet("schema/" + keysDB + "/" + keysTable)
+	tblInfo, err := getTableInfo(keysDB + "." + keysTable)
 	if err != nil {
 		return err
 	}
-	if status != http.StatusOK {
-		fmt.Println("status=", status)
-		fmt.Println(string(body))
-		return nil
-	}
-
-	type response struct {
-		TableID   int64 `json:"id"`
-		TableName struct {
-			Name string `json:"O"`
-		} `json:"name"`
-		Indexes []struct {
-			IndexID   int64 `json:"id"`
-			IndexName struct {
-				Name string `json:"O"`
-			} `json:"idx_name"`
-		} `json:"index_info"`
-	}
-	var res response
-	err = json.Unmarshal(body, &res)
-	if err != nil {
-		fmt.Println("invalid response:", string(body))
-		return err
-	}
 	var indexIDs []int64
 	var indexNames []string
-	for _, idx := range res.Indexes {
-		indexIDs = append(indexIDs, idx.IndexID)
-		indexNames = append(indexNames, idx.IndexName.Name)
+	for _, idx := range tblInfo.Indices {
+		indexIDs = append(indexIDs, idx.ID)
+		indexNames = append(indexNames, idx.Name.O)
 	}
-	printTableKeyRanges(res.TableID, keysTable, indexIDs, indexNames)
+	printTableKeyRanges(tblInfo.ID, keysTable, indexIDs, indexNames)
 	return nil
 }
 
@@ -0,0 +1,386 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// The values of kvrpcpb.Op, which is used by both MVCC locks and writes.
+	opPut             = 0
+	opDel             = 1
+	opLock            = 2
+	opRollback        = 3
+	opInsert          = 4
+	opPessimisticLock = 5
+
+	lockStatusCommitted  = "committed"
+	lockStatusRolledBack = "rolled back"
+	lockStatusPending    = "pending"
+	lockStatusUnknown    = "unknown"
+
+	lockActionCommit   = "commit"
+	lockActionRollback = "rollback"
+	lockActionWait     = "wait"
+	lockActionCheck    = "check"
+
+	// physicalShiftBits is the number of logical bits in a TSO.
+	physicalShiftBits = 18
+	// maxLockScanHandles limits the number of keys scanned by one command.
+	maxLockScanHandles = 10000
+)
+
+// lock command flags
+var (
+	lockDB       string
+	lockTable    string
+	lockHID      int64
+	lockEndHID   int64
+	lockStartTS  uint64
+	lockHIDIsSet bool
+	// lockMaxTxnTTL judges whether a lock is expired by its age if the TTL is not reported.
+	lockMaxTxnTTL time.Duration
+)
+
+// mvccResponse is the response of the MVCC APIs of the TiDB status port.
+type mvccResponse struct {
+	Key      string `json:"key"`
+	RegionID uint64 `json:"region_id"`
+	Value    struct {
+		Error string    `json:"error"`
+		Info  *mvccInfo `json:"info"`
+	} `json:"value"`
+}
+
+type mvccInfo struct {
+	Lock   *mvccLock   `json:"lock"`
+	Writes []mvccWrite `json:"writes"`
+}
+
+type mvccLock struct {
+	Type    int32  `json:"type"`
+	StartTS uint64 `json:"start_ts"`
+	Primary []byte `json:"primary"`
+	// TTL is only reported by the newer versions of TiKV, it is 0 if it is not reported.
+	TTL uint64 `json:"ttl"`
+}
+
+type mvccWrite struct {
+	Type     int32  `json:"type"`
+	StartTS  uint64 `json:"start_ts"`
+	CommitTS uint64 `json:"commit_ts"`
+}
+
+// lockInfo is a lock found in MVCC info together with the state of its transaction.
+type lockInfo struct {
+	Key      string `json:"key"`
+	RegionID uint64 `json:"region_id"`
+	Type     string `json:"type"`
+	StartTS  uint64 `json:"start_ts"`
+	Primary  string `json:"primary"`
+	TTL      uint64 `json:"ttl"`
+	// TTLUnknown is true if the TTL is not reported, then the lock is expired if it is older than --max-txn-ttl.
+	TTLUnknown bool   `json:"ttl_unknown"`
+	Expired    bool   `json:"expired"`
+	Status     string `json:"status"`
+	CommitTS   uint64 `json:"commit_ts,omitempty"`
+	Action     string `json:"action"`
+}
+
+// lockRootCmd represents the lock command
+var lockRootCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Lock information",
+	Long:  "Inspect the locks in MVCC information and check the state of their transactions",
+}
+
+// lockListCmd represents the list locks command
+var lockListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List locks and the state of their primary keys",
+	Long: `List locks, e.g.
+* tidb-ctl lock ls --database(-d) [database name] --table(-t) [table name] --hid(-i) [handle] [--end-hid [handle]]
+Check the record keys of the handles in [hid, end-hid].
+* tidb-ctl lock ls [hex key]...
+Check the specified keys.
+* tidb-ctl lock ls --start-ts(-s) [start timestamp] --database(-d) [database name] --table(-t) [table name]
+Check the first key of a transaction in the table.`,
+	RunE: listLocks,
+}
+
+// lockPlanCmd represents the lock resolve plan command
+var lockPlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show how the locks should be resolved",
+	Long:  "tidb-ctl lock plan accepts the same arguments as `tidb-ctl lock ls` and prints the resolve plan in JSON",
+	RunE:  planLocks,
+}
+
+func init() {
+	handleFlagName := "hid"
+	endHandleFlagName := "end-hid"
+	startTSFlagName := "start-ts"
+
+	lockRootCmd.AddCommand(lockListCmd, lockPlanCmd)
+
+	lockRootCmd.PersistentFlags().StringVarP(&lockDB, dbFlagName, "d", "", "database name")
+	lockRootCmd.PersistentFlags().StringVarP(&lockTable, tableFlagName, "t", "", "table name")
+	lockRootCmd.PersistentFlags().Int64VarP(&lockHID, handleFlagName, "i", 0, "the first handle to check")
+	lockRootCmd.PersistentFlags().Int64Var(&lockEndHID, endHandleFlagName, 0, "the last handle to check, default to --hid")
+	lockRootCmd.PersistentFlags().Uint64VarP(&lockStartTS, startTSFlagName, "s", 0, "check the first key of a transaction with the start ts")
+	lockRootCmd.PersistentFlags().DurationVar(&lockMaxTxnTTL, "max-txn-ttl", time.Hour,
+		"the max TTL of the transactions (performance.max-txn-ttl of TiDB), the locks older than it are expired if TiKV does not report the TTL")
+	lockRootCmd.PersistentPreRunE = func(c *cobra.Command, args []string) error {
+		lockHIDIsSet = c.Flag(handleFlagName).Changed
+		if !c.Flag(endHandleFlagName).Changed {
+			lockEndHID = lockHID
+		}
+		return setupCommand(c, args)
+	}
+}
+
+func listLocks(c *cobra.Command, args []string) error {
+	locks, err := collectLocks(args)
+	if err != nil {
+		return err
+	}
+	if len(locks) == 0 {
+		c.Println("no lock found")
+		return nil
+	}
+	for _, l := range locks {
+		c.Printf("key: %s (%s)\n", l.Key, describeHexKey(l.Key))
+		c.Printf("  region_id: %d\n", l.RegionID)
+		c.Printf("  type: %s\n", l.Type)
+		c.Printf("  start_ts: %d (%s)\n", l.StartTS, tsToTime(l.StartTS).Format(time.RFC3339))
+		c.Printf("  primary: %s (%s)\n", l.Primary, describeHexKey(l.Primary))
+		if l.TTLUnknown {
+			c.Printf("  ttl: unavailable, expired: %v (older than --max-txn-ttl %s)\n", l.Expired, lockMaxTxnTTL)
+		} else {
+			c.Printf("  ttl: %dms, expired: %v\n", l.TTL, l.Expired)
+		}
+		if l.Status == lockStatusCommitted {
+			c.Printf("  primary status: %s, commit_ts: %d\n", l.Status, l.CommitTS)
+		} else {
+			c.Printf("  primary status: %s\n", l.Status)
+		}
+		c.Printf("  recommendation: %s\n", lockRecommendation(l))
+	}
+	return nil
+}
+
+func planLocks(c *cobra.Command, args []string) error {
+	locks, err := collectLocks(args)
+	if err != nil {
+		return err
+	}
+	if locks == nil {
+		locks = []*lockInfo{}
+	}
+	plan, err := json.MarshalIndent(locks, "", "    ")
+	if err != nil {
+		return err
+	}
+	c.Println(string(plan))
+	return nil
+}
+
+// collectLocks fetches the MVCC info of the keys specified by args and flags, and returns the locks in them.
+func collectLocks(args []string) ([]*lockInfo, error) {
+	var paths []string
+	switch {
+	case len(args) > 0:
+		for _, key := range args {
+			paths = append(paths, hexPrefix+key)
+		}
+	case lockHIDIsSet:
+		if len(lockDB) == 0 || len(lockTable) == 0 {
+			return nil, errors.New("database name and table name should be set with handle")
+		}
+		if lockEndHID < lockHID {
+			return nil, errors.Errorf("end handle %d is less than handle %d", lockEndHID, lockHID)
+		}
+		if lockEndHID-lockHID >= maxLockScanHandles {
+			return nil, errors.Errorf("too many handles, at most %d handles can be checked once", maxLockScanHandles)
+		}
+		for h := lockHID; h <= lockEndHID; h++ {
+			paths = append(paths, keyPrefix+lockDB+"/"+lockTable+"/"+strconv.FormatInt(h, 10))
+		}
+	case lockStartTS != 0:
+		if len(lockDB) == 0 || len(lockTable) == 0 {
+			return nil, errors.New("database name and table name should be set with start ts")
+		}
+		paths = append(paths, txnPrefix+strconv.FormatUint(lockStartTS, 10)+"/"+lockDB+"/"+lockTable)
+	default:
+		return nil, errors.New("no key specified")
+	}
+
+	var locks []*lockInfo
+	for _, path := range paths {
+		resp, err := getMvcc(path)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Value.Info == nil || resp.Value.Info.Lock == nil {
+			continue
+		}
+		l, err := checkLock(resp)
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, l)
+	}
+	return locks, nil
+}
+
+// checkLock checks the state of the transaction that the lock in resp belongs to by the MVCC info of its primary key.
+func checkLock(resp *mvccResponse) (*lockInfo, error) {
+	lock := resp.Value.Info.Lock
+	l := &lockInfo{
+		Key:      strings.ToUpper(resp.Key),
+		RegionID: resp.RegionID,
+		Type:     opName(lock.Type),
+		StartTS:  lock.StartTS,
+		Primary:  strings.ToUpper(hex.EncodeToString(lock.Primary)),
+		TTL:      lock.TTL,
+		Status:   lockStatusUnknown,
+	}
+	l.TTLUnknown = l.TTL == 0
+	l.Expired = time.Now().After(lockExpireAt(l))
+
+	primaryInfo := resp.Value.Info
+	if l.Primary != l.Key {
+		primaryResp, err := getMvcc(hexPrefix + l.Primary)
+		if err != nil {
+			return nil, err
+		}
+		primaryInfo = primaryResp.Value.Info
+	}
+	if primaryInfo != nil {
+		if primaryInfo.Lock != nil && primaryInfo.Lock.StartTS == l.StartTS {
+			l.Status = lockStatusPending
+		}
+		for _, w := range primaryInfo.Writes {
+			if w.StartTS != l.StartTS {
+				continue
+			}
+			if w.Type == opRollback {
+				l.Status = lockStatusRolledBack
+			} else {
+				l.Status = lockStatusCommitted
+				l.CommitTS = w.CommitTS
+			}
+			break
+		}
+	}
+
+	switch l.Status {
+	case lockStatusCommitted:
+		l.Action = lockActionCommit
+	case lockStatusRolledBack:
+		l.Action = lockActionRollback
+	case lockStatusPending:
+		if l.Expired {
+			l.Action = lockActionRollback
+		} else {
+			l.Action = lockActionWait
+		}
+	default:
+		l.Action = lockActionCheck
+	}
+	return l, nil
+}
+
+func lockRecommendation(l *lockInfo) string {
+	switch l.Action {
+	case lockActionCommit:
+		return fmt.Sprintf("the transaction is committed, the lock should be committed with commit_ts %d", l.CommitTS)
+	case lockActionRollback:
+		if l.Status == lockStatusPending && l.TTLUnknown {
+			return fmt.Sprintf("the primary lock is older than --max-txn-ttl %s, the transaction should be rolled back", lockMaxTxnTTL)
+		}
+		if l.Status == lockStatusPending {
+			return "the primary lock is expired, the transaction should be rolled back"
+		}
+		return "the transaction is rolled back, the lock should be rolled back"
+	case lockActionWait:
+		if l.TTLUnknown {
+			return fmt.Sprintf("the transaction may be in progress, the TTL is unavailable, wait until the lock is older than --max-txn-ttl at %s",
+				lockExpireAt(l).Format(time.RFC3339))
+		}
+		return fmt.Sprintf("the transaction is in progress, wait until the lock expires at %s", lockExpireAt(l).Format(time.RFC3339))
+	default:
+		return "no record of the transaction is found on the primary key, it may have been resolved or garbage collected"
+	}
+}
+
+// lockExpireAt returns when the lock expires, which is judged by --max-txn-ttl if the TTL is unknown.
+func lockExpireAt(l *lockInfo) time.Time {
+	if l.TTLUnknown {
+		return tsToTime(l.StartTS).Add(lockMaxTxnTTL)
+	}
+	return tsToTime(l.StartTS).Add(time.Duration(l.TTL) * time.Millisecond)
+}
+
+func getMvcc(path string) (*mvccResponse, error) {
+	resp := &mvccResponse{}
+	if err := httpGetJSON(path, resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Value.Error) != 0 {
+		return nil, errors.Errorf("get MVCC info of %s failed: %s", path, resp.Value.Error)
+	}
+	return resp, nil
+}
+
+func describeHexKey(key string) string {
+	raw, err := hex.DecodeString(key)
+	if err != nil {
+		return err.Error()
+	}
+	return describeKey(raw)
+}
+
+func opName(op int32) string {
+	switch op {
+	case opPut:
+		return "Put"
+	case opDel:
+		return "Del"
+	case opLock:
+		return "Lock"
+	case opRollback:
+		return "Rollback"
+	case opInsert:
+		return "Insert"
+	case opPessimisticLock:
+		return "PessimisticLock"
+	}
+	return strconv.Itoa(int(op))
+}
+
+// tsToTime returns the physical time of a TSO.
+func tsToTime(ts uint64) time.Time {
+	ms := int64(ts >> physicalShiftBits)
+	return time.Unix(ms/1e3, (ms%1e3)*1e6)
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/spf13/cobra"
+)
+
+var _ = Suite(&lockTestSuite{})
+
+type lockTestSuite struct{}
+
+const (
+	testPrimaryKey   = "7480000000000000015F728000000000000001"
+	testSecondaryKey = "7480000000000000015F728000000000000002"
+	// base64 of testPrimaryKey
+	testPrimaryBase64 = "dIAAAAAAAAABX3KAAAAAAAAAAQ=="
+)
+
+// newLockTestCommand returns the root command of the lock command,
+// the root command of initCommand uses -i as the shorthand of pdhost, which conflicts with --hid.
+func newLockTestCommand() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.AddCommand(lockRootCmd)
+	cmd.PersistentFlags().IPVar(&host, "host", net.ParseIP("127.0.0.1"), "TiDB server host")
+	cmd.PersistentFlags().Uint16Var(&port, "port", 10080, "TiDB server port")
+	return cmd
+}
+
+func (s *lockTestSuite) TestLockPlan(c *C) {
+	primaryWrites := `[{"type":3,"start_ts":100,"commit_ts":100}]`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		switch r.URL.EscapedPath() {
+		case "/" + hexPrefix + testSecondaryKey:
+			_, err := w.Write([]byte(`{"key":"` + testSecondaryKey + `","region_id":2,"value":{"info":{"lock":{"start_ts":100,"primary":"` + testPrimaryBase64 + `","ttl":3000}}}}`))
+			c.Assert(err, IsNil)
+		case "/" + hexPrefix + testPrimaryKey:
+			_, err := w.Write([]byte(`{"key":"` + testPrimaryKey + `","region_id":2,"value":{"info":{"writes":` + primaryWrites + `}}}`))
+			c.Assert(err, IsNil)
+		default:
+			c.Fatalf("unexpected path %s", r.URL.EscapedPath())
+		}
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	c.Assert(err, IsNil)
+	uArr := strings.Split(u.Host, ":")
+	cmd := newLockTestCommand()
+	args := []string{"lock", "plan", testSecondaryKey, "--host", uArr[0], "--port", uArr[1]}
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	var locks []*lockInfo
+	c.Assert(json.Unmarshal(output, &locks), IsNil)
+	c.Assert(locks, HasLen, 1)
+	c.Assert(locks[0].Primary, Equals, testPrimaryKey)
+	c.Assert(locks[0].Status, Equals, lockStatusRolledBack)
+	c.Assert(locks[0].Action, Equals, lockActionRollback)
+	c.Assert(locks[0].Expired, IsTrue)
+
+	primaryWrites = `[{"start_ts":100,"commit_ts":101}]`
+	args = []string{"lock", "ls", testSecondaryKey, "--host", uArr[0], "--port", uArr[1]}
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Matches, "(?s).*primary: "+testPrimaryKey+" \\(table_id: 1, handle: 1\\).*")
+	c.Assert(string(output), Matches, "(?s).*primary status: committed, commit_ts: 101.*")
+}
+
+func (s *lockTestSuite) TestLockTTLUnavailable(c *C) {
+	// The MVCC locks of TiKV 4.0 have no TTL, the primary lock is pending.
+	var startTS uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock := `{"start_ts":` + strconv.FormatUint(startTS, 10) + `,"primary":"` + testPrimaryBase64 + `","short_value":"MQ=="}`
+		var resp string
+		switch r.URL.EscapedPath() {
+		case "/" + hexPrefix + testSecondaryKey:
+			resp = `{"key":"` + testSecondaryKey + `","region_id":2,"value":{"info":{"lock":` + lock + `}}}`
+		case "/" + hexPrefix + testPrimaryKey:
+			resp = `{"key":"` + testPrimaryKey + `","region_id":2,"value":{"info":{"lock":` + lock + `,"writes":[{"start_ts":90,"commit_ts":95}]}}}`
+		default:
+			c.Fatalf("unexpected path %s", r.URL.EscapedPath())
+		}
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(resp))
+		c.Assert(err, IsNil)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	c.Assert(err, IsNil)
+	uArr := strings.Split(u.Host, ":")
+	cmd := newLockTestCommand()
+
+	// The lock older than --max-txn-ttl is expired.
+	startTS = uint64(time.Now().Add(-2*time.Hour).UnixNano()/int64(time.Millisecond)) << physicalShiftBits
+	args := []string{"lock", "plan", testSecondaryKey, "--host", uArr[0], "--port", uArr[1]}
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Matches, `(?s).*"ttl": 0,\s+"ttl_unknown": true,\s+"expired": true,\s+"status": "pending",\s+"action": "rollback".*`)
+	args = []string{"lock", "ls", testSecondaryKey, "--host", uArr[0], "--port", uArr[1]}
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Matches, `(?s).*ttl: unavailable, expired: true \(older than --max-txn-ttl 1h0m0s\)\n.*`+
+		`recommendation: the primary lock is older than --max-txn-ttl 1h0m0s, the transaction should be rolled back\n`)
+
+	startTS = uint64(time.Now().UnixNano()/int64(time.Millisecond)) << physicalShiftBits
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Matches, `(?s).*ttl: unavailable, expired: false .*`+
+		`recommendation: the transaction may be in progress, the TTL is unavailable, wait until the lock is older than --max-txn-ttl at .*`)
+}
+
+func (s *lockTestSuite) TestOpName(c *C) {
+	c.Assert(opName(opInsert), Equals, "Insert")
+	c.Assert(opName(opPessimisticLock), Equals, "PessimisticLock")
+	c.Assert(opName(6), Equals, "6")
+}
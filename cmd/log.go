@@ -5,20 +5,47 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 )
 
-var logOutputPath string
+// log command flags
+var (
+	logOutputPath   string
+	logLevel        string
+	logSince        string
+	logUntil        string
+	logConn         string
+	logTxnStartTS   string
+	logMessageRegex string
+	logFields       []string
+	logMerge        bool
+	logFormat       string
+	logFollow       bool
+)
 
 // logCmd is used to format convert single-line log to multiple-line form
 var logCmd = &cobra.Command{
 	Use:   "log",
 	Short: "convert single-line log to multiple-line form",
-	Long:  `log /path/to/tidb.log [/path/to/tidb2.log] [-o /path/to/tidb.converted.log]`,
-	RunE:  prettyLogFunc,
+	Long: `log /path/to/tidb.log [/path/to/tidb2.log] [-o /path/to/tidb.converted.log]
+The log is read from stdin if no file is given, and '-o -' writes to stdout, so it can be used in pipelines, e.g.
+* kubectl logs tidb-0 | tidb-ctl log --level warn -o - | less
+The logs compressed by gzip or zstd are decompressed.
+The records of the unified log format '[time] [LEVEL] [file:line] [message] [key=value]...' can be filtered, e.g.
+* tidb-ctl log tidb.log --level warn --since "2020-05-20 10:00:00" --until "2020-05-20 11:00:00"
+* tidb-ctl log tidb.log --conn 3 --message-regex "(?i)slow query"
+* tidb-ctl log tidb.log --txn-start-ts 416592340193280002 --field category=ddl
+The logs of several instances, including the rotated logs compressed by gzip or zstd, can be merged by time, e.g.
+* tidb-ctl log tidb1/tidb.log tidb1/tidb-2020-05-20T10-00-00.000.log.gz tidb2/tidb.log --merge
+The records can be converted to JSON lines of {time, level, source, message, fields}, e.g.
+* tidb-ctl log tidb.log --format json
+The log can be followed like 'tail -F', the converted records are written to stdout until interrupted, e.g.
+* tidb-ctl log -f tidb.log --level warn`,
+	RunE: prettyLogFunc,
 }
 
 type converter struct {
@@ -45,9 +72,7 @@ func (c *converter) Read(p []byte) (n int, err error) {
 			return 0, err
 		}
 		if n > 0 {
-			replNewLine := strings.ReplaceAll(string(buffer[:n]), "\\n", "\n")
-			replTab := strings.ReplaceAll(string(replNewLine), "\\t", "\t")
-			c.buffer.WriteString(strings.ReplaceAll(replTab, "\r", ""))
+			c.buffer.WriteString(unescapeLogText(string(buffer[:n])))
 		}
 		if err == io.EOF {
 			break
@@ -61,28 +86,77 @@ func (c *converter) Close() error {
 	return c.reader.Close()
 }
 
-func prettyLogFunc(_ *cobra.Command, args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("at least one log file needs to be specified")
+func prettyLogFunc(c *cobra.Command, args []string) error {
+	logStdin = c.InOrStdin()
+	if len(args) == 0 {
+		args = []string{stdinPath}
+	}
+	filter, err := newLogFilter(logLevel, logSince, logUntil, logConn, logTxnStartTS, logMessageRegex, logFields)
+	if err != nil {
+		return err
+	}
+	if logFollow {
+		if len(args) != 1 {
+			return fmt.Errorf("only one log file can be followed")
+		}
+		if args[0] == stdinPath {
+			return fmt.Errorf("stdin cannot be followed, pipe 'tail -F' to tidb-ctl log instead")
+		}
+		if logOutputPath != "" && logOutputPath != stdinPath {
+			return fmt.Errorf("the followed records are written to stdout, --output cannot be used with --follow")
+		}
+		if logMerge {
+			return fmt.Errorf("--merge cannot be used with --follow")
+		}
+		stop, done := make(chan struct{}), make(chan struct{})
+		defer close(done)
+		interrupted := make(chan os.Signal, 1)
+		signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(interrupted)
+		go func() {
+			select {
+			case <-interrupted:
+				close(stop)
+			case <-done:
+			}
+		}()
+		return followLog(c.OutOrStdout(), args[0], logFormat, filter, followPollInterval, stop)
 	}
 	if logOutputPath == "" {
 		logOutputPath = fmt.Sprintf("tidb-ctl-log-converted.%s.log", time.Now().Format("2006-02-03.15.04.05.999"))
 	}
-	output, err := os.OpenFile(logOutputPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.ModePerm)
+	output := c.OutOrStdout()
+	if logOutputPath != stdinPath {
+		file, err := os.OpenFile(logOutputPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.ModePerm)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := file.Close(); closeErr != nil {
+				fmt.Printf("file close error: %v", closeErr)
+			}
+		}()
+		output = file
+	}
+
+	writer, err := newLogRecordWriter(output, logFormat, logMerge)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if closeErr := output.Close(); closeErr != nil {
-			fmt.Printf("file close error: %v", closeErr)
-		}
-	}()
-
+	if logMerge {
+		return mergeLogs(writer, args, filter)
+	}
 	for _, path := range args {
-		input, err := os.OpenFile(path, os.O_RDONLY, os.ModePerm)
+		input, err := openLogInput(path)
 		if err != nil {
 			return err
 		}
+		if !filter.isEmpty() || logFormat != logFormatText {
+			if err := filterLog(writer, input, path, filter); err != nil {
+				return err
+			}
+			continue
+		}
 		c := newConverter(input)
 		if _, err := io.Copy(output, c); err != nil && err != io.EOF {
 			return err
@@ -94,6 +168,41 @@ func prettyLogFunc(_ *cobra.Command, args []string) error {
 	return nil
 }
 
+// filterLog writes the records matching the filter, and closes the input.
+func filterLog(output logRecordWriter, input io.ReadCloser, path string, filter *logFilter) (err error) {
+	defer func() {
+		if closeErr := input.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	reader := newLogRecordReader(input)
+	for {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !filter.match(rec) {
+			continue
+		}
+		if err = output.write(rec, path); err != nil {
+			return err
+		}
+	}
+}
+
 func init() {
-	logCmd.Flags().StringVarP(&logOutputPath, "output", "o", "", "the converted log file output path")
+	logCmd.Flags().StringVarP(&logOutputPath, "output", "o", "", "the converted log file output path, - means stdout")
+	logCmd.Flags().StringVar(&logLevel, "level", "", "only keep the records of the level and higher levels: debug, info, warn, error or fatal")
+	logCmd.Flags().StringVar(&logSince, "since", "", "only keep the records since the time, e.g. \"2020-05-20 10:00:00\"")
+	logCmd.Flags().StringVar(&logUntil, "until", "", "only keep the records before the time")
+	logCmd.Flags().StringVar(&logConn, "conn", "", "only keep the records of the connection ID")
+	logCmd.Flags().StringVar(&logTxnStartTS, "txn-start-ts", "", "only keep the records of the transaction start ts")
+	logCmd.Flags().StringVar(&logMessageRegex, "message-regex", "", "only keep the records whose message matches the regular expression")
+	logCmd.Flags().StringVar(&logFormat, "format", logFormatText, "the output format: text or json")
+	logCmd.Flags().BoolVarP(&logFollow, "follow", "f", false, "follow the appended records of the log and write them to stdout until interrupted")
+	logCmd.Flags().BoolVar(&logMerge, "merge", false, "merge the logs by time instead of concatenating them, each line is tagged by its log path")
+	logCmd.Flags().StringArrayVar(&logFields, "field", nil, "only keep the records with the field, in the form of key=value, or key for any value")
 }
@@ -0,0 +1,411 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&logTestSuite{})
+
+type logTestSuite struct{}
+
+func (s *logTestSuite) SetUpTest(c *C) {
+	resetLogFlags()
+}
+
+// resetLogFlags resets the flags of the log command, which are kept between executions.
+func resetLogFlags() {
+	logOutputPath, logLevel, logSince, logUntil, logConn, logTxnStartTS, logMessageRegex = "", "", "", "", "", "", ""
+	logFields = nil
+	logMerge, logFollow = false, false
+	logFormat = logFormatText
+	summarySince, summaryUntil, summaryTop = "", "", 20
+	conflictsSince, conflictsUntil, conflictsTop, conflictsLookupSchema = "", "", 10, false
+}
+
+const testLog = `[2020/05/20 10:00:00.000 +08:00] [INFO] [server.go:100] ["new connection"] [conn=1] [remoteAddr=127.0.0.1:5000]
+[2020/05/20 10:00:01.000 +08:00] [WARN] [session.go:200] ["compile SQL failed"] [conn=1] [SQL="select \"a]\"\nfrom t"]
+[2020/05/20 10:00:02.000 +08:00] [ERROR] [2pc.go:300] ["prewrite failed"] [conn=2] [txnStartTS=416592340193280002] [error="write conflict"]
+goroutine 1 [running]:
+[2020/05/20 10:00:03.000 +08:00] [INFO] [ddl_worker.go:400] ["[ddl] run DDL job"] [category=ddl] [job="ID:2, Type:create table"]
+`
+
+func (s *logTestSuite) TestParseLogRecord(c *C) {
+	rec, ok := parseLogRecord(`[2020/05/20 10:00:01.000 +08:00] [WARN] [session.go:200] ["compile SQL failed"] [conn=1] [SQL="select \"a]\"\nfrom t"] ["quoted key"=v]`)
+	c.Assert(ok, IsTrue)
+	c.Assert(rec.Time.Equal(time.Date(2020, 5, 20, 2, 0, 1, 0, time.UTC)), IsTrue)
+	c.Assert(rec.Level, Equals, "WARN")
+	c.Assert(rec.Source, Equals, "session.go:200")
+	c.Assert(rec.Message, Equals, "compile SQL failed")
+	c.Assert(rec.Fields, DeepEquals, []logField{{"conn", "1"}, {"SQL", "select \"a]\"\nfrom t"}, {"quoted key", "v"}})
+
+	_, ok = parseLogRecord("goroutine 1 [running]:")
+	c.Assert(ok, IsFalse)
+	_, ok = parseLogRecord("[2020/05/20 10:00:01.000 +08:00] [UNKNOWN] [a.go:1] [msg]")
+	c.Assert(ok, IsFalse)
+
+	reader := newLogRecordReader(strings.NewReader(testLog))
+	var messages []string
+	for rec, err := reader.Next(); err == nil; rec, err = reader.Next() {
+		messages = append(messages, rec.Message)
+	}
+	c.Assert(messages, DeepEquals, []string{"new connection", "compile SQL failed", "prewrite failed\ngoroutine 1 [running]:", "[ddl] run DDL job"})
+}
+
+func (s *logTestSuite) TestFilterLog(c *C) {
+	dir := c.MkDir()
+	input := filepath.Join(dir, "tidb.log")
+	c.Assert(ioutil.WriteFile(input, []byte(testLog), 0644), IsNil)
+	output := filepath.Join(dir, "tidb.converted.log")
+	cmd := initCommand()
+	run := func(args ...string) string {
+		resetLogFlags()
+		args = append([]string{"log", input, "-o", output}, args...)
+		_, _, err := executeCommandC(cmd, args...)
+		c.Assert(err, IsNil)
+		data, err := ioutil.ReadFile(output)
+		c.Assert(err, IsNil)
+		return string(data)
+	}
+	c.Assert(run("--level", "warn"), Equals, `[2020/05/20 10:00:01.000 +08:00] [WARN] [session.go:200] ["compile SQL failed"] [conn=1] [SQL="select \"a]\"`+"\n"+`from t"]
+[2020/05/20 10:00:02.000 +08:00] [ERROR] [2pc.go:300] ["prewrite failed"] [conn=2] [txnStartTS=416592340193280002] [error="write conflict"]
+goroutine 1 [running]:
+`)
+	c.Assert(run("--conn", "1", "--since", "2020-05-20T10:00:00.500+08:00"), Matches, `\[2020/05/20 10:00:01.000 \+08:00\] \[WARN\][^\n]*\n[^\n]*\n`)
+	c.Assert(run("--txn-start-ts", "416592340193280002"), Matches, `(?s)\[2020/05/20 10:00:02.000 \+08:00\] \[ERROR\].*running\]:\n`)
+	c.Assert(run("--message-regex", "DDL", "--field", "category=ddl"), Matches, `\[2020/05/20 10:00:03.000 \+08:00\] \[INFO\] [^\n]*\n`)
+	c.Assert(run("--message-regex", "DDL", "--field", "category=txn"), Equals, "")
+
+	c.Assert(run("--level", "error", "--format", "json"), Equals, `{"time":"2020-05-20T10:00:02.000+08:00","level":"ERROR","source":"2pc.go:300",`+
+		`"message":"prewrite failed\ngoroutine 1 [running]:","fields":{"conn":"2","error":"write conflict","txnStartTS":"416592340193280002"}}
+`)
+	c.Assert(run("--conn", "1", "--level", "warn", "--format", "json"), Equals, `{"time":"2020-05-20T10:00:01.000+08:00","level":"WARN","source":"session.go:200",`+
+		`"message":"compile SQL failed","fields":{"SQL":"select \"a]\"\nfrom t","conn":"1"}}
+`)
+
+	_, _, err := executeCommandC(cmd, "log", input, "-o", output, "--level", "trace")
+	c.Assert(err, ErrorMatches, "invalid level trace.*")
+}
+
+func (s *logTestSuite) TestMergeLogs(c *C) {
+	dir := c.MkDir()
+	log1 := filepath.Join(dir, "tidb1.log")
+	c.Assert(ioutil.WriteFile(log1, []byte(`[2020/05/20 10:00:00.000 +08:00] [INFO] [a.go:1] [a1]
+[2020/05/20 10:00:02.000 +08:00] [INFO] [a.go:1] ["a2\nnext line"]
+`), 0644), IsNil)
+	log2 := filepath.Join(dir, "tidb2.log.gz")
+	file, err := os.Create(log2)
+	c.Assert(err, IsNil)
+	w := gzip.NewWriter(file)
+	_, err = w.Write([]byte(`[2020/05/20 10:00:01.000 +08:00] [WARN] [b.go:1] [b1]
+[2020/05/20 10:00:02.000 +08:00] [INFO] [b.go:1] [b2]
+[2020/05/20 10:00:03.000 +08:00] [INFO] [b.go:1] [b3]
+`))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+	c.Assert(file.Close(), IsNil)
+
+	output := filepath.Join(dir, "merged.log")
+	cmd := initCommand()
+	_, _, err = executeCommandC(cmd, "log", log1, log2, "--merge", "-o", output)
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadFile(output)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "["+log1+"] [2020/05/20 10:00:00.000 +08:00] [INFO] [a.go:1] [a1]\n"+
+		"["+log2+"] [2020/05/20 10:00:01.000 +08:00] [WARN] [b.go:1] [b1]\n"+
+		"["+log1+"] [2020/05/20 10:00:02.000 +08:00] [INFO] [a.go:1] [\"a2\n"+
+		"["+log1+"] next line\"]\n"+
+		"["+log2+"] [2020/05/20 10:00:02.000 +08:00] [INFO] [b.go:1] [b2]\n"+
+		"["+log2+"] [2020/05/20 10:00:03.000 +08:00] [INFO] [b.go:1] [b3]\n")
+}
+
+func (s *logTestSuite) TestLogPipeline(c *C) {
+	// The gzip log from stdin is decompressed and the records are written to stdout.
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, err := w.Write([]byte(testLog))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+	cmd := initCommand()
+	cmd.SetIn(&compressed)
+	_, output, err := executeCommandC(cmd, "log", "--level", "error", "-o", "-")
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "[2020/05/20 10:00:02.000 +08:00] [ERROR] [2pc.go:300] [\"prewrite failed\"] [conn=2] [txnStartTS=416592340193280002] [error=\"write conflict\"]\n"+
+		"goroutine 1 [running]:\n")
+
+	resetLogFlags()
+	cmd = initCommand()
+	cmd.SetIn(strings.NewReader("[2020/05/20 10:00:00.000 +08:00] [INFO] [a.go:1] [\"a\\nb\"]\n"))
+	_, output, err = executeCommandC(cmd, "log", "-", "-o", "-")
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "[2020/05/20 10:00:00.000 +08:00] [INFO] [a.go:1] [\"a\nb\"]\n")
+
+	var zstdCompressed bytes.Buffer
+	zw, err := zstd.NewWriter(&zstdCompressed)
+	c.Assert(err, IsNil)
+	_, err = zw.Write([]byte(testLog))
+	c.Assert(err, IsNil)
+	c.Assert(zw.Close(), IsNil)
+	input := filepath.Join(c.MkDir(), "tidb.log.zst")
+	c.Assert(ioutil.WriteFile(input, zstdCompressed.Bytes(), 0644), IsNil)
+	resetLogFlags()
+	cmd = initCommand()
+	_, output, err = executeCommandC(cmd, "log", input, "--conn", "1", "-o", "-")
+	c.Assert(err, IsNil)
+	c.Assert(strings.Count(string(output), "[conn=1]"), Equals, 2)
+}
+
+func (s *logTestSuite) TestLogSummary(c *C) {
+	c.Assert(maskLogText(`region 12 epoch not match, key 7480000000000000ff2d5f728000000000000001, ts 0x1a2b, ID 2f1e46c7-3d2b-4d8e-9b1a-0c8f1e2d3c4b, addr 127.0.0.1:20160, took 1.5s`),
+		Equals, `region <num> epoch not match, key <key>, ts <hex>, ID <uuid>, addr <num>:<num>, took <num>`)
+	c.Assert(maskLogText(`key: t\x80\x00\x00\x00\x00\x00\x00\x2d_r, primary: []`), Equals, `key: <key>, primary: []`)
+
+	dir := c.MkDir()
+	log1 := filepath.Join(dir, "tidb1.log")
+	c.Assert(ioutil.WriteFile(log1, []byte(`[2020/05/20 10:00:00.000 +08:00] [INFO] [owner_manager.go:1] ["[ddl] become DDL owner"] [ownerID=1]
+[2020/05/20 10:00:01.000 +08:00] [WARN] [2pc.go:1] ["prewrite encounters lock"] [conn=1] [txnStartTS=416592340193280002]
+[2020/05/20 10:00:02.000 +08:00] [ERROR] [2pc.go:2] ["commit failed"] [conn=1] [error="[kv:9007]Write conflict, txnStartTS=416592340193280002, conflictStartTS=416592340193280001"]
+[2020/05/20 10:00:03.000 +08:00] [WARN] [2pc.go:1] ["prewrite encounters lock"] [conn=2] [txnStartTS=416592340193280003]
+`), 0644), IsNil)
+	log2 := filepath.Join(dir, "tidb2.log")
+	c.Assert(ioutil.WriteFile(log2, []byte(`[2020/05/20 09:00:00.000 +08:00] [ERROR] [2pc.go:2] ["commit failed"] [conn=5] [error="[kv:9007]Write conflict, txnStartTS=416592340193280005, conflictStartTS=416592340193280004"]
+[2020/05/20 10:00:04.000 +08:00] [WARN] [2pc.go:1] ["prewrite encounters lock"] [conn=6] [txnStartTS=416592340193280006]
+[2020/05/20 10:00:05.000 +08:00] [WARN] [memory.go:1] ["memory exceeds quota, rateLimitAction delegate to fallback action"] [quota=1073741824]
+`), 0644), IsNil)
+
+	cmd := initCommand()
+	_, output, err := executeCommandC(cmd, "log", "summary", log1, log2)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "6 error and warning records, 3 templates\n"+
+		"LEVEL  COUNT  "+log1+"  "+log2+"  TEMPLATE\n"+
+		"ERROR  2      1"+strings.Repeat(" ", len(log1)+1)+"1"+strings.Repeat(" ", len(log2)+1)+"commit failed error=[kv:<num>]Write conflict, txnStartTS=<num>, conflictStartTS=<num>\n"+
+		"WARN   3      2"+strings.Repeat(" ", len(log1)+1)+"1"+strings.Repeat(" ", len(log2)+1)+"prewrite encounters lock\n"+
+		"WARN   1      0"+strings.Repeat(" ", len(log1)+1)+"1"+strings.Repeat(" ", len(log2)+1)+"memory exceeds quota, rateLimitAction delegate to fallback action\n"+
+		"\n"+
+		"critical events:\n"+
+		"EVENT             COUNT  FIRST_SEEN                      LAST_SEEN\n"+
+		"DDL owner change  1      2020/05/20 10:00:00.000 +08:00  2020/05/20 10:00:00.000 +08:00\n"+
+		"write conflict    2      2020/05/20 09:00:00.000 +08:00  2020/05/20 10:00:02.000 +08:00\n"+
+		"OOM action        1      2020/05/20 10:00:05.000 +08:00  2020/05/20 10:00:05.000 +08:00\n")
+
+	cmd = initCommand()
+	_, output, err = executeCommandC(cmd, "log", "summary", log1, "--since", "2020/05/20 10:00:02.000 +08:00", "-n", "1")
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "2 error and warning records, 2 templates\n"+
+		"LEVEL  COUNT  "+log1+"  TEMPLATE\n"+
+		"ERROR  1      1"+strings.Repeat(" ", len(log1)+1)+"commit failed error=[kv:<num>]Write conflict, txnStartTS=<num>, conflictStartTS=<num>\n"+
+		"\n"+
+		"critical events:\n"+
+		"EVENT           COUNT  FIRST_SEEN                      LAST_SEEN\n"+
+		"write conflict  1      2020/05/20 10:00:02.000 +08:00  2020/05/20 10:00:02.000 +08:00\n")
+}
+
+func (s *logTestSuite) TestLogConflicts(c *C) {
+	conflict, ok := extractWriteConflict(`[kv:9007]Write conflict, txnStartTS=416592340193280003, conflictStartTS=416592340193280001, conflictCommitTS=416592340193280002, key={tableID=45, indexID=1, indexValues={b1, }} primary={tableID=45, handle=1} [try again later]`)
+	c.Assert(ok, IsTrue)
+	c.Assert(conflict, DeepEquals, &writeConflict{txnStartTS: 416592340193280003, startTS: 416592340193280001, commitTS: 416592340193280002,
+		key: "{tableID=45, indexID=1, indexValues={b1, }}", primary: "{tableID=45, handle=1}"})
+	_, ok = extractWriteConflict("prewrite encounters lock, conflictStartTS=1")
+	c.Assert(ok, IsFalse)
+	conflict, ok = extractDeadlock(`pessimistic lock failed txnStartTS=416592340193280005 error=deadlock(lock_ts:416592340193280004 lock_key:"74800000000000002d5f728000000000000002" deadlock_key_hash:123)`)
+	c.Assert(ok, IsTrue)
+	c.Assert(conflict, DeepEquals, &writeConflict{deadlock: true, txnStartTS: 416592340193280005, startTS: 416592340193280004,
+		key: "74800000000000002d5f728000000000000002"})
+	_, ok = extractDeadlock("[tikv:1213]Deadlock found when trying to get lock; try restarting transaction")
+	c.Assert(ok, IsFalse)
+	c.Assert(formatKeyInfo(decodeLoggedKey("74800000000000002d5f728000000000000002"), ""), Equals, "table_id 45, handle 2")
+	c.Assert(formatKeyInfo(decodeLoggedKey(`t\x80\x00\x00\x00\x00\x00\x00\x2d_r\x80\x00\x00\x00\x00\x00\x00\x03`), ""), Equals, "table_id 45, handle 3")
+	c.Assert(formatKeyInfo(decodeLoggedKey("unknown"), "unknown"), Equals, "unknown")
+
+	input := filepath.Join(c.MkDir(), "tidb.log")
+	c.Assert(ioutil.WriteFile(input, []byte(`[2020/05/20 10:00:00.000 +08:00] [WARN] [txn.go:1] ["commit failed"] [error="[kv:9007]Write conflict, txnStartTS=416592340193280011, conflictStartTS=416592340193280001, conflictCommitTS=416592340193280002, key={tableID=45, handle=1} primary={tableID=45, handle=1} [try again later]"]
+[2020/05/20 10:00:01.000 +08:00] [WARN] [txn.go:1] ["commit failed"] [error="[kv:9007]Write conflict, txnStartTS=416592340193280012, conflictStartTS=416592340193280001, conflictCommitTS=416592340193280002, key={tableID=45, indexID=1, indexValues={b1, }} primary={tableID=45, handle=2} [try again later]"]
+[2020/05/20 10:00:02.000 +08:00] [WARN] [txn.go:1] ["commit failed"] [error="[kv:9007]Write conflict, txnStartTS=416592340193280013, conflictStartTS=416592340193280003, conflictCommitTS=416592340193280004, key=74800000000000002d5f728000000000000001 primary=74800000000000002d5f728000000000000001"]
+[2020/05/20 10:00:03.000 +08:00] [INFO] [txn.go:1] ["retry txn"] [txnStartTS=416592340193280013]
+[2020/05/20 10:00:04.000 +08:00] [WARN] [txn.go:2] ["pessimistic lock failed"] [txnStartTS=416592340193280014] [error="deadlock(lock_ts:416592340193280001 lock_key:\"74800000000000002d5f728000000000000001\" deadlock_key_hash:123)"]
+`), 0644), IsNil)
+	path := writeTestSnapshot(c, "schema.json", testSchemaSnapshot)
+	defer func() { schemaFile = "" }()
+	cmd := initCommand()
+	_, output, err := executeCommandC(cmd, "log", "conflicts", input, "--schema-file", path)
+	c.Assert(err, IsNil)
+	commitTime := func(ts uint64) string { return tsToTime(ts).Format(logTimeLayout) }
+	c.Assert(string(output), Equals, "3 write conflicts, 1 deadlocks, 2 keys, 2 conflicting transactions\n"+
+		"\n"+
+		"hot keys:\n"+
+		"RANK  COUNT  KEY\n"+
+		"1     3      test.t, handle 1\n"+
+		"2     1      test.t, index idx_b (b1)\n"+
+		"\n"+
+		"hot primary keys of the conflicted transactions:\n"+
+		"RANK  COUNT  KEY\n"+
+		"1     2      test.t, handle 1\n"+
+		"2     1      test.t, handle 2\n"+
+		"\n"+
+		"conflicting transactions:\n"+
+		"RANK  COUNT  CONFLICT_START_TS   CONFLICT_COMMIT_TS  COMMIT_TIME\n"+
+		"1     3      416592340193280001  416592340193280002  "+commitTime(416592340193280002)+"\n"+
+		"2     1      416592340193280003  416592340193280004  "+commitTime(416592340193280004)+"\n"+
+		"\n"+
+		"failed and conflicting transactions:\n"+
+		"RANK  COUNT  TYPE            TXN_START_TS        CONFLICT_START_TS   KEY\n"+
+		"1     1      write conflict  416592340193280011  416592340193280001  test.t, handle 1\n"+
+		"2     1      write conflict  416592340193280012  416592340193280001  test.t, index idx_b (b1)\n"+
+		"3     1      write conflict  416592340193280013  416592340193280003  test.t, handle 1\n"+
+		"4     1      deadlock        416592340193280014  416592340193280001  test.t, handle 1\n")
+}
+
+const testSlowLog = `# Time: 2020-05-20T10:00:00.000000+08:00
+# Txn_start_ts: 416592340193280001
+# User@Host: root[root] @ 127.0.0.1 [127.0.0.1]
+# Query_time: 1.5
+# Process_time: 0.5 Wait_time: 0.1 Process_keys: 1000
+# Mem_max: 2048
+# Plan: tidb_decode_plan('abc')
+use test;
+select * from t where a = 1;
+# Time: 2020-05-20T10:00:01.000000+08:00
+# Txn_start_ts: 416592340193280002
+# User@Host: root[root] @ 127.0.0.1 [127.0.0.1]
+# Query_time: 2.5
+# Cop_time: 1 Process_keys: 3000
+# Mem_max: 1024
+# Plan: tidb_decode_plan('def')
+select * from t where a = 2;
+# Time: 2020-05-20T10:00:02.000000+08:00
+# Txn_start_ts: 416592340193280003
+# User: app@127.0.0.1
+# Query_time: 3
+# Digest: 0123456789abcdef0123
+insert into t values (1);
+`
+
+func (s *logTestSuite) TestSlowLog(c *C) {
+	input := filepath.Join(c.MkDir(), "tidb-slow.log")
+	c.Assert(ioutil.WriteFile(input, []byte(testSlowLog), 0644), IsNil)
+	cmd := initCommand()
+	_, output, err := executeCommandC(cmd, "log", "slow", input, "--plan")
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `3 queries, 2 digests
+RANK  DIGEST            COUNT  TOTAL   AVG     P99     COP_TIME  PROCESS_KEYS  MEM_MAX  SLOWEST_TXN_START_TS  SQL
+1     233ddc91cd773861  2      4.000s  2.000s  2.500s  1.600s    4000          2.0 KiB  416592340193280002    select * from t where a = ?
+2     0123456789abcdef  1      3.000s  3.000s  3.000s  0.000s    0             0 B      416592340193280003    insert into t values ( ? )
+plan of the slowest query of rank 1 (2.500s at 2020-05-20 10:00:01.000):
+tidb_decode_plan('def')
+`)
+
+	_, output, err = executeCommandC(cmd, "log", "slow", input, "--plan=false", "--user", "root", "--since", "2020-05-20T10:00:00.5+08:00", "-n", "1")
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Matches, "1 queries, 1 digests\n.*\n1 .* select \\* from t where a = \\?\n")
+}
+
+func (s *logTestSuite) TestFollowLogFlags(c *C) {
+	path := filepath.Join(c.MkDir(), "tidb.log")
+	c.Assert(ioutil.WriteFile(path, []byte(testLog), 0644), IsNil)
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{path, path}, "only one log file can be followed"},
+		{[]string{path, "-o", filepath.Join(c.MkDir(), "out.log")}, "the followed records are written to stdout, --output cannot be used with --follow"},
+		{[]string{path, "--merge"}, "--merge cannot be used with --follow"},
+	} {
+		resetLogFlags()
+		cmd := initCommand()
+		_, _, err := executeCommandC(cmd, append([]string{"log", "-f"}, t.args...)...)
+		c.Assert(err, ErrorMatches, t.err)
+	}
+}
+
+// syncBuffer is a buffer which can be written and read concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitForOutput polls the output until it is expected, or fails after a timeout.
+func waitForOutput(c *C, output *syncBuffer, expected string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for output.String() != expected && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	c.Assert(output.String(), Equals, expected)
+}
+
+func (s *logTestSuite) TestFollowLog(c *C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "tidb.log")
+	appendLog := func(content string) {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		c.Assert(err, IsNil)
+		_, err = file.WriteString(content)
+		c.Assert(err, IsNil)
+		c.Assert(file.Close(), IsNil)
+	}
+
+	for _, level := range []string{"", "warn"} {
+		c.Assert(ioutil.WriteFile(path, []byte("[2020/05/20 10:00:00.000 +08:00] [INFO] [a.go:1] [old]\n"), 0644), IsNil)
+		filter, err := newLogFilter(level, "", "", "", "", "", nil)
+		c.Assert(err, IsNil)
+		stop := make(chan struct{})
+		follower, err := newLogFollower(path, time.Millisecond, stop)
+		c.Assert(err, IsNil)
+		var output syncBuffer
+		done := make(chan error)
+		go func() {
+			done <- writeFollowedLog(&output, follower, logFormatText, filter)
+		}()
+		// The last record is written without waiting for the next record, even if the line is appended in parts.
+		appendLog("[2020/05/20 10:00:01.000 +08:00] [WARN] [a.go:1] [\"new")
+		appendLog("\\nline\"]\n")
+		waitForOutput(c, &output, "[2020/05/20 10:00:01.000 +08:00] [WARN] [a.go:1] [\"new\nline\"]\n")
+		// Rotate the log.
+		c.Assert(os.Rename(path, filepath.Join(dir, "tidb-2020-05-20T10-00-01.000.log")), IsNil)
+		appendLog("[2020/05/20 10:00:02.000 +08:00] [INFO] [a.go:1] [rotated]\n")
+		appendLog("[2020/05/20 10:00:03.000 +08:00] [ERROR] [a.go:1] [last]\n")
+		if level == "" {
+			waitForOutput(c, &output, "[2020/05/20 10:00:01.000 +08:00] [WARN] [a.go:1] [\"new\nline\"]\n"+
+				"[2020/05/20 10:00:02.000 +08:00] [INFO] [a.go:1] [rotated]\n"+
+				"[2020/05/20 10:00:03.000 +08:00] [ERROR] [a.go:1] [last]\n")
+		} else {
+			waitForOutput(c, &output, "[2020/05/20 10:00:01.000 +08:00] [WARN] [a.go:1] [\"new\nline\"]\n"+
+				"[2020/05/20 10:00:03.000 +08:00] [ERROR] [a.go:1] [last]\n")
+		}
+		close(stop)
+		c.Assert(<-done, IsNil)
+	}
+}
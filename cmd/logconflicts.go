@@ -0,0 +1,407 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// log conflicts command flags
+var (
+	conflictsSince        string
+	conflictsUntil        string
+	conflictsTop          int
+	conflictsLookupSchema bool
+)
+
+var logConflictsCmd = &cobra.Command{
+	Use:   "conflicts",
+	Short: "analyze the write conflicts and deadlocks in the logs",
+	Long: `Extract the conflicting transactions and keys from the write conflict and deadlock records, decode the keys
+and rank the hot keys, the conflicting transactions and the pairs of the failed and conflicting transactions by frequency, e.g.
+* tidb-ctl log conflicts tidb1/tidb.log tidb2/tidb.log [-n 10]
+The table and index names are looked up from TiDB with --lookup-schema, or from the schema snapshot, e.g.
+* tidb-ctl log conflicts tidb.log --lookup-schema
+* tidb-ctl log conflicts tidb.log --schema-file schema.json`,
+	RunE: analyzeConflicts,
+}
+
+func init() {
+	logCmd.AddCommand(logConflictsCmd)
+	logConflictsCmd.Flags().StringVar(&conflictsSince, "since", "", "only analyze the records since the time, e.g. \"2020-05-20 10:00:00\"")
+	logConflictsCmd.Flags().StringVar(&conflictsUntil, "until", "", "only analyze the records before the time")
+	logConflictsCmd.Flags().IntVarP(&conflictsTop, "top", "n", 10, "the number of keys and transactions to print, 0 means all")
+	logConflictsCmd.Flags().BoolVar(&conflictsLookupSchema, "lookup-schema", false, "look up the table and index names of the keys from TiDB")
+}
+
+var (
+	writeConflictPattern    = regexp.MustCompile(`(?i)write ?conflict`)
+	conflictStartTSPattern  = regexp.MustCompile(`\bconflictStartTS=(\d+)`)
+	conflictCommitTSPattern = regexp.MustCompile(`\bconflictCommitTS=(\d+)`)
+	conflictKeyPattern      = regexp.MustCompile(`\bkey=`)
+	conflictPrimaryPattern  = regexp.MustCompile(`\bprimary=`)
+	conflictTxnPattern      = regexp.MustCompile(`(?i)\btxn_?start_?ts[=:]\s*(\d+)`)
+	// The deadlock errors of TiKV are printed as deadlock(lock_ts:1 lock_key:"t\200..." deadlock_key_hash:2).
+	deadlockPattern        = regexp.MustCompile(`(?i)\bdeadlock`)
+	deadlockLockTSPattern  = regexp.MustCompile(`(?i)\block_?ts[=:]\s*(\d+)`)
+	deadlockLockKeyPattern = regexp.MustCompile(`(?i)\block_?key[=:]\s*`)
+	// The keys may be printed as {tableID=45, handle=1} or {tableID=45, indexID=1, indexValues={1, }}.
+	loggedTableIDPattern     = regexp.MustCompile(`\btableID=(-?\d+)`)
+	loggedHandlePattern      = regexp.MustCompile(`\bhandle=(-?\d+)`)
+	loggedIndexIDPattern     = regexp.MustCompile(`\bindexID=(-?\d+)`)
+	loggedIndexValuesPattern = regexp.MustCompile(`\bindexValues=\{([^}]*)\}`)
+)
+
+// writeConflict is a write conflict or a deadlock extracted from a log record, the transaction of txnStartTS fails
+// since it conflicts with the transaction of startTS, commitTS and primary are unknown for deadlocks.
+type writeConflict struct {
+	deadlock   bool
+	txnStartTS uint64
+	startTS    uint64
+	commitTS   uint64
+	key        string
+	primary    string
+}
+
+// conflictCount is the number of conflicts of a key, a transaction or a pair of transactions.
+type conflictCount struct {
+	name     string
+	commitTS uint64
+	count    int
+	// row is the description of the pair of transactions.
+	row []string
+}
+
+// conflictCounter counts the conflicts by name in the order of first occurrence.
+type conflictCounter struct {
+	counts map[string]*conflictCount
+	order  []*conflictCount
+}
+
+func newConflictCounter() *conflictCounter {
+	return &conflictCounter{counts: make(map[string]*conflictCount)}
+}
+
+func (c *conflictCounter) add(name string) *conflictCount {
+	count, ok := c.counts[name]
+	if !ok {
+		count = &conflictCount{name: name}
+		c.counts[name] = count
+		c.order = append(c.order, count)
+	}
+	count.count++
+	return count
+}
+
+// top returns the n most frequent ones, all are returned if n is not positive.
+func (c *conflictCounter) top(n int) []*conflictCount {
+	sort.SliceStable(c.order, func(i, j int) bool { return c.order[i].count > c.order[j].count })
+	if n > 0 && len(c.order) > n {
+		return c.order[:n]
+	}
+	return c.order
+}
+
+// extractWriteConflict extracts the write conflict from the text of a record, false is returned if it is not found.
+func extractWriteConflict(text string) (*writeConflict, bool) {
+	if !writeConflictPattern.MatchString(text) {
+		return nil, false
+	}
+	m := conflictStartTSPattern.FindStringSubmatch(text)
+	if m == nil {
+		return nil, false
+	}
+	conflict := &writeConflict{}
+	var err error
+	if conflict.startTS, err = strconv.ParseUint(m[1], 10, 64); err != nil {
+		return nil, false
+	}
+	if m = conflictCommitTSPattern.FindStringSubmatch(text); m != nil {
+		if conflict.commitTS, err = strconv.ParseUint(m[1], 10, 64); err != nil {
+			return nil, false
+		}
+	}
+	conflict.txnStartTS = extractTxnStartTS(text)
+	conflict.key = extractLoggedKey(text, conflictKeyPattern)
+	conflict.primary = extractLoggedKey(text, conflictPrimaryPattern)
+	return conflict, true
+}
+
+// extractDeadlock extracts the deadlock from the text of a record, false is returned if it is not found.
+func extractDeadlock(text string) (*writeConflict, bool) {
+	if !deadlockPattern.MatchString(text) {
+		return nil, false
+	}
+	m := deadlockLockTSPattern.FindStringSubmatch(text)
+	if m == nil {
+		return nil, false
+	}
+	conflict := &writeConflict{deadlock: true}
+	var err error
+	if conflict.startTS, err = strconv.ParseUint(m[1], 10, 64); err != nil {
+		return nil, false
+	}
+	conflict.txnStartTS = extractTxnStartTS(text)
+	conflict.key = strings.Trim(extractLoggedKey(text, deadlockLockKeyPattern), `\"`)
+	return conflict, true
+}
+
+// extractTxnStartTS returns the start ts of the failed transaction, 0 is returned if it is not found.
+func extractTxnStartTS(text string) uint64 {
+	m := conflictTxnPattern.FindStringSubmatch(text)
+	if m == nil {
+		return 0
+	}
+	ts, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return ts
+}
+
+// extractLoggedKey returns the key following the pattern, which ends at the matched brace or a space.
+func extractLoggedKey(text string, pattern *regexp.Regexp) string {
+	loc := pattern.FindStringIndex(text)
+	if loc == nil {
+		return ""
+	}
+	rest := text[loc[1]:]
+	if strings.HasPrefix(rest, "{") {
+		depth := 0
+		for i := 0; i < len(rest); i++ {
+			switch rest[i] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					return rest[:i+1]
+				}
+			}
+		}
+		return rest
+	}
+	if end := strings.IndexAny(rest, " \t\n"); end >= 0 {
+		rest = rest[:end]
+	}
+	return strings.TrimRight(rest, ",")
+}
+
+// decodeLoggedKey decodes a key in the log, which is in the form of {tableID=45, handle=1}, hex or escaped.
+func decodeLoggedKey(key string) *keyInfo {
+	if !strings.HasPrefix(key, "{") {
+		raw, err := parseKey(key)
+		if err != nil {
+			return &keyInfo{Type: keyTypeUnknown}
+		}
+		return decodeKeyPrefix(raw)
+	}
+	k := &keyInfo{Type: keyTypeUnknown}
+	parseInt := func(pattern *regexp.Regexp) (int64, bool) {
+		m := pattern.FindStringSubmatch(key)
+		if m == nil {
+			return 0, false
+		}
+		v, err := strconv.ParseInt(m[1], 10, 64)
+		return v, err == nil
+	}
+	var ok bool
+	if k.TableID, ok = parseInt(loggedTableIDPattern); !ok {
+		return k
+	}
+	k.Type = keyTypeTable
+	if handle, ok := parseInt(loggedHandlePattern); ok {
+		k.Type, k.Handle = keyTypeRecord, &handle
+	} else if k.IndexID, ok = parseInt(loggedIndexIDPattern); ok {
+		k.Type = keyTypeIndex
+		if m := loggedIndexValuesPattern.FindStringSubmatch(key); m != nil {
+			for _, v := range strings.Split(m[1], ",") {
+				if v = strings.TrimSpace(v); len(v) != 0 {
+					k.IndexValues = append(k.IndexValues, v)
+				}
+			}
+		}
+	}
+	return k
+}
+
+// formatKeyInfo returns a human-readable form of a decoded key, the original key is returned if it is unknown.
+func formatKeyInfo(k *keyInfo, original string) string {
+	if k.TableID == 0 {
+		return original
+	}
+	var b strings.Builder
+	if len(k.TableName) != 0 {
+		b.WriteString(k.DBName + "." + k.TableName)
+		if len(k.PartitionName) != 0 {
+			b.WriteString(" partition " + k.PartitionName)
+		}
+	} else {
+		b.WriteString("table_id " + strconv.FormatInt(k.TableID, 10))
+	}
+	switch k.Type {
+	case keyTypeRecord:
+		if k.Handle != nil {
+			b.WriteString(fmt.Sprintf(", handle %d", *k.Handle))
+		}
+	case keyTypeIndex:
+		if len(k.IndexName) != 0 {
+			b.WriteString(", index " + k.IndexName)
+		} else {
+			b.WriteString(fmt.Sprintf(", index_id %d", k.IndexID))
+		}
+		b.WriteString(" (" + strings.Join(k.IndexValues, ", ") + ")")
+	}
+	return b.String()
+}
+
+func analyzeConflicts(c *cobra.Command, args []string) error {
+	logStdin = c.InOrStdin()
+	if len(args) == 0 {
+		args = []string{stdinPath}
+	}
+	filter, err := newLogFilter("", conflictsSince, conflictsUntil, "", "", "", nil)
+	if err != nil {
+		return err
+	}
+	var conflicts []*writeConflict
+	for _, path := range args {
+		input, err := openLogInput(path)
+		if err != nil {
+			return err
+		}
+		err = readLogRecords(input, func(rec *logRecord) {
+			if len(rec.Level) == 0 || !filter.match(rec) {
+				return
+			}
+			text := rec.Message
+			for _, f := range rec.Fields {
+				text += " " + f.Key + "=" + f.Value
+			}
+			if conflict, ok := extractWriteConflict(text); ok {
+				conflicts = append(conflicts, conflict)
+			} else if conflict, ok := extractDeadlock(text); ok {
+				conflicts = append(conflicts, conflict)
+			}
+		})
+		if closeErr := input.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	lookup := conflictsLookupSchema || len(schemaFile) != 0
+	tables := make(map[int64]*dbTableInfo)
+	decoded := make(map[string]string)
+	describe := func(key string) (string, error) {
+		if desc, ok := decoded[key]; ok {
+			return desc, nil
+		}
+		k := decodeLoggedKey(key)
+		if lookup {
+			if err := fillKeyNames(k, tables); err != nil {
+				return "", err
+			}
+		}
+		desc := formatKeyInfo(k, key)
+		decoded[key] = desc
+		return desc, nil
+	}
+	formatTS := func(ts uint64) string {
+		if ts == 0 {
+			return "-"
+		}
+		return strconv.FormatUint(ts, 10)
+	}
+	keys, primaries, txns, pairs := newConflictCounter(), newConflictCounter(), newConflictCounter(), newConflictCounter()
+	deadlocks := 0
+	for _, conflict := range conflicts {
+		for _, kc := range []struct {
+			key     string
+			counter *conflictCounter
+		}{{conflict.key, keys}, {conflict.primary, primaries}} {
+			if len(kc.key) == 0 {
+				continue
+			}
+			desc, err := describe(kc.key)
+			if err != nil {
+				return err
+			}
+			kc.counter.add(desc)
+		}
+		txn := txns.add(strconv.FormatUint(conflict.startTS, 10))
+		if conflict.commitTS != 0 {
+			txn.commitTS = conflict.commitTS
+		}
+		kind := "write conflict"
+		if conflict.deadlock {
+			kind = "deadlock"
+			deadlocks++
+		}
+		key := "-"
+		if len(conflict.key) != 0 {
+			// The key has been described above.
+			key = decoded[conflict.key]
+		}
+		row := []string{kind, formatTS(conflict.txnStartTS), strconv.FormatUint(conflict.startTS, 10), key}
+		pairs.add(strings.Join(row, "\x00")).row = row
+	}
+
+	c.Printf("%d write conflicts, %d deadlocks, %d keys, %d conflicting transactions\n",
+		len(conflicts)-deadlocks, deadlocks, len(keys.order), len(txns.order))
+	if len(conflicts) == 0 {
+		return nil
+	}
+	for _, kc := range []struct {
+		title   string
+		counter *conflictCounter
+	}{{"hot keys", keys}, {"hot primary keys of the conflicted transactions", primaries}} {
+		if len(kc.counter.order) == 0 {
+			continue
+		}
+		c.Printf("\n%s:\n", kc.title)
+		var rows [][]string
+		for i, k := range kc.counter.top(conflictsTop) {
+			rows = append(rows, []string{strconv.Itoa(i + 1), strconv.Itoa(k.count), k.name})
+		}
+		printTable(c, []string{"RANK", "COUNT", "KEY"}, rows)
+	}
+	c.Println("\nconflicting transactions:")
+	var rows [][]string
+	for i, txn := range txns.top(conflictsTop) {
+		commitTS, commitTime := "-", "-"
+		if txn.commitTS != 0 {
+			commitTS = strconv.FormatUint(txn.commitTS, 10)
+			commitTime = tsToTime(txn.commitTS).Format(logTimeLayout)
+		}
+		rows = append(rows, []string{strconv.Itoa(i + 1), strconv.Itoa(txn.count), txn.name, commitTS, commitTime})
+	}
+	printTable(c, []string{"RANK", "COUNT", "CONFLICT_START_TS", "CONFLICT_COMMIT_TS", "COMMIT_TIME"}, rows)
+
+	c.Println("\nfailed and conflicting transactions:")
+	rows = nil
+	for i, pair := range pairs.top(conflictsTop) {
+		rows = append(rows, append([]string{strconv.Itoa(i + 1), strconv.Itoa(pair.count)}, pair.row...))
+	}
+	printTable(c, []string{"RANK", "COUNT", "TYPE", "TXN_START_TS", "CONFLICT_START_TS", "KEY"}, rows)
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pingcap/errors"
+)
+
+// followPollInterval is the interval to check the new content and the rotation of the followed log.
+const followPollInterval = 200 * time.Millisecond
+
+// errLogIdle is returned by the follower once when it has read all the content of the log,
+// so that the readers can write out the record they hold before waiting for the new content.
+var errLogIdle = errors.New("no new content in the log")
+
+// logFollower reads the content appended to a log until it is stopped, like `tail -F`.
+// When the log is rotated, the rest of the old log is read before the new log.
+type logFollower struct {
+	path     string
+	file     *os.File
+	offset   int64
+	interval time.Duration
+	stop     <-chan struct{}
+	// idle is whether errLogIdle has been returned since the last content.
+	idle bool
+}
+
+// newLogFollower opens the log and starts from the end of it.
+func newLogFollower(path string, interval time.Duration, stop <-chan struct{}) (*logFollower, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		if closeErr := file.Close(); closeErr != nil {
+			return nil, closeErr
+		}
+		return nil, err
+	}
+	return &logFollower{path: path, file: file, offset: offset, interval: interval, stop: stop}, nil
+}
+
+// Read blocks until there is new content, io.EOF is returned after the follower is stopped.
+// errLogIdle is returned when it reaches the end of the log for the first time since the last content.
+func (f *logFollower) Read(p []byte) (int, error) {
+	for {
+		n, err := f.file.Read(p)
+		f.offset += int64(n)
+		if n > 0 {
+			f.idle = false
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if !f.idle {
+			f.idle = true
+			return 0, errLogIdle
+		}
+		if err = f.checkRotation(); err != nil {
+			return 0, err
+		}
+		select {
+		case <-f.stop:
+			return 0, io.EOF
+		case <-time.After(f.interval):
+		}
+	}
+}
+
+// checkRotation reopens the log if it is rotated, or reads from the start if it is truncated.
+func (f *logFollower) checkRotation() error {
+	current, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+	latest, err := os.Stat(f.path)
+	if err != nil {
+		// The new log may be not created yet.
+		return nil
+	}
+	if !os.SameFile(current, latest) {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return nil
+		}
+		if err = f.file.Close(); err != nil {
+			return err
+		}
+		f.file, f.offset = file, 0
+		return nil
+	}
+	if current.Size() < f.offset {
+		if _, err = f.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		f.offset = 0
+	}
+	return nil
+}
+
+func (f *logFollower) Close() error {
+	return f.file.Close()
+}
+
+// followLog writes the records appended to the log until it is stopped.
+func followLog(output io.Writer, path string, format string, filter *logFilter, interval time.Duration, stop <-chan struct{}) error {
+	follower, err := newLogFollower(path, interval, stop)
+	if err != nil {
+		return err
+	}
+	return writeFollowedLog(output, follower, format, filter)
+}
+
+// writeFollowedLog writes the records read by the follower and closes it.
+// The lines are written as soon as they arrive if there is no filter and the format is text,
+// otherwise a record is written when the next record arrives or the log has no new content,
+// since it may have continuation lines.
+func writeFollowedLog(output io.Writer, follower *logFollower, format string, filter *logFilter) (err error) {
+	writer, err := newLogRecordWriter(output, format, false)
+	if err != nil {
+		if closeErr := follower.Close(); closeErr != nil {
+			return closeErr
+		}
+		return err
+	}
+	if filter.isEmpty() && format == logFormatText {
+		defer func() {
+			if closeErr := follower.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}()
+		reader := bufio.NewReader(follower)
+		// partial is the beginning of the line whose rest is not written to the log yet.
+		var partial string
+		for {
+			line, err := reader.ReadString('\n')
+			if err == errLogIdle {
+				partial += line
+				continue
+			}
+			line, partial = partial+line, ""
+			if len(line) > 0 {
+				if _, writeErr := io.WriteString(output, unescapeLogText(line)); writeErr != nil {
+					return writeErr
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return filterLog(writer, follower, follower.path, filter)
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pingcap/errors"
+)
+
+// stdinPath is the path of the log read from stdin.
+const stdinPath = "-"
+
+// The magic numbers of the compressed logs.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// logStdin is the stdin of the log commands.
+var logStdin io.Reader = os.Stdin
+
+// logInput is a log input which closes the decompressor and the underlying file.
+type logInput struct {
+	io.Reader
+	closers []func() error
+}
+
+func (in *logInput) Close() error {
+	var err error
+	for _, closeFunc := range in.closers {
+		if closeErr := closeFunc(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// openLogInput opens the log file, or stdin if the path is "-".
+// The logs compressed by gzip or zstd are decompressed.
+func openLogInput(path string) (io.ReadCloser, error) {
+	in := &logInput{}
+	if path == stdinPath {
+		in.closers = append(in.closers, func() error { return nil })
+		return decompressLogInput(path, logStdin, in)
+	}
+	file, err := os.OpenFile(path, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	in.closers = append(in.closers, file.Close)
+	decompressed, err := decompressLogInput(path, file, in)
+	if err != nil {
+		if closeErr := file.Close(); closeErr != nil {
+			return nil, closeErr
+		}
+		return nil, err
+	}
+	return decompressed, nil
+}
+
+// decompressLogInput detects the compression by the magic number and sets the reader of in.
+func decompressLogInput(path string, reader io.Reader, in *logInput) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(reader)
+	magic, err := buffered.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, errors.Errorf("cannot decompress %s: %v", path, err)
+		}
+		in.Reader = gzipReader
+		// The gzip reader should be closed before the file.
+		in.closers = append([]func() error{gzipReader.Close}, in.closers...)
+	case bytes.HasPrefix(magic, zstdMagic):
+		zstdReader, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, errors.Errorf("cannot decompress %s: %v", path, err)
+		}
+		in.Reader = zstdReader
+		in.closers = append([]func() error{func() error {
+			zstdReader.Close()
+			return nil
+		}}, in.closers...)
+	default:
+		in.Reader = buffered
+	}
+	return in, nil
+}
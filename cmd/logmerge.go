@@ -0,0 +1,118 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"container/heap"
+	"io"
+	"time"
+)
+
+// logCursor is the current record of a log in the merge.
+type logCursor struct {
+	source string
+	index  int
+	reader *logRecordReader
+	input  io.Closer
+	rec    *logRecord
+	// time is the time of the current record, or the time of the previous record if the current one has no time.
+	time time.Time
+}
+
+// advance moves to the next record matching the filter, false is returned if there are no more records.
+func (c *logCursor) advance(filter *logFilter) (bool, error) {
+	for {
+		rec, err := c.reader.Next()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if !rec.Time.IsZero() {
+			c.time = rec.Time
+		}
+		if filter.match(rec) {
+			c.rec = rec
+			return true, nil
+		}
+	}
+}
+
+type logHeap []*logCursor
+
+func (h logHeap) Len() int { return len(h) }
+
+func (h logHeap) Less(i, j int) bool {
+	if h[i].time.Equal(h[j].time) {
+		return h[i].index < h[j].index
+	}
+	return h[i].time.Before(h[j].time)
+}
+
+func (h logHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *logHeap) Push(x interface{}) { *h = append(*h, x.(*logCursor)) }
+
+func (h *logHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// mergeLogs writes the records of the logs matching the filter in the order of time.
+func mergeLogs(output logRecordWriter, paths []string, filter *logFilter) (err error) {
+	var cursors []*logCursor
+	defer func() {
+		for _, c := range cursors {
+			if closeErr := c.input.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+	}()
+	h := &logHeap{}
+	for i, path := range paths {
+		input, err := openLogInput(path)
+		if err != nil {
+			return err
+		}
+		c := &logCursor{source: path, index: i, reader: newLogRecordReader(input), input: input}
+		cursors = append(cursors, c)
+		ok, err := c.advance(filter)
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Push(h, c)
+		}
+	}
+	for h.Len() > 0 {
+		c := (*h)[0]
+		if err = output.write(c.rec, c.source); err != nil {
+			return err
+		}
+		ok, err := c.advance(filter)
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return nil
+}
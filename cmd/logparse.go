@@ -0,0 +1,436 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+)
+
+// logTimeLayout is the time layout of the unified log format.
+const logTimeLayout = "2006/01/02 15:04:05.000 -07:00"
+
+// The field keys of the connection ID and the transaction start ts in TiDB logs, in lower case.
+var (
+	logConnKeys       = []string{"conn", "connid", "connectionid"}
+	logTxnStartTSKeys = []string{"txnstartts", "startts", "start_ts", "txn_start_ts"}
+)
+
+var logLevels = map[string]int{
+	"DEBUG":   1,
+	"INFO":    2,
+	"WARN":    3,
+	"WARNING": 3,
+	"ERROR":   4,
+	"FATAL":   5,
+}
+
+// logField is a `[key=value]` field of a log record.
+type logField struct {
+	Key   string
+	Value string
+}
+
+// logRecord is a record of the unified log format `[time] [LEVEL] [file:line] [message] [key=value]...`.
+type logRecord struct {
+	Time    time.Time
+	Level   string
+	Source  string
+	Message string
+	Fields  []logField
+	// Raw is the original text of the record, including the continuation lines.
+	Raw string
+}
+
+// field returns the value of the first field with one of the keys, the keys are compared case-insensitively.
+func (r *logRecord) field(keys ...string) (string, bool) {
+	for _, f := range r.Fields {
+		for _, k := range keys {
+			if strings.EqualFold(f.Key, k) {
+				return f.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseLogRecord parses a line of the unified log format, false is returned if the line is not in the format.
+func parseLogRecord(line string) (*logRecord, bool) {
+	items, ok := splitLogItems(line)
+	if !ok || len(items) < 4 {
+		return nil, false
+	}
+	t, err := time.Parse(logTimeLayout, items[0])
+	if err != nil {
+		return nil, false
+	}
+	level := strings.ToUpper(items[1])
+	if _, ok := logLevels[level]; !ok {
+		return nil, false
+	}
+	r := &logRecord{
+		Time:    t,
+		Level:   level,
+		Source:  items[2],
+		Message: unquoteLogValue(items[3]),
+		Raw:     line,
+	}
+	for _, item := range items[4:] {
+		key, value := splitLogField(item)
+		r.Fields = append(r.Fields, logField{Key: key, Value: value})
+	}
+	return r, true
+}
+
+// splitLogItems splits the line into the contents of the `[...]` items, the quoted values are kept as they are.
+func splitLogItems(line string) ([]string, bool) {
+	var items []string
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+		if line[i] != '[' {
+			return items, len(items) > 0
+		}
+		end := i + 1
+		for end < len(line) && line[end] != ']' {
+			if line[end] == '"' {
+				end = skipQuoted(line, end)
+				continue
+			}
+			end++
+		}
+		if end >= len(line) {
+			return nil, false
+		}
+		items = append(items, line[i+1:end])
+		i = end + 1
+	}
+	return items, len(items) > 0
+}
+
+// skipQuoted returns the position after the quoted string starting at the position start.
+func skipQuoted(s string, start int) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// splitLogField splits a `key=value` item, the key and the value may be quoted.
+func splitLogField(item string) (string, string) {
+	sep := strings.IndexByte(item, '=')
+	if strings.HasPrefix(item, `"`) {
+		end := skipQuoted(item, 0)
+		sep = strings.IndexByte(item[end:], '=')
+		if sep >= 0 {
+			sep += end
+		}
+	}
+	if sep < 0 {
+		return unquoteLogValue(item), ""
+	}
+	return unquoteLogValue(item[:sep]), unquoteLogValue(item[sep+1:])
+}
+
+// unquoteLogValue removes the quotes and escapes of a quoted value.
+func unquoteLogValue(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	if v, err := strconv.Unquote(s); err == nil {
+		return v
+	}
+	// The value is escaped as JSON strings, which may be invalid for Go.
+	return unescapeLogText(strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s[1 : len(s)-1]))
+}
+
+// unescapeLogText unescapes the line breaks and tabs in logs, and removes the carriage returns.
+func unescapeLogText(s string) string {
+	replNewLine := strings.ReplaceAll(s, "\\n", "\n")
+	replTab := strings.ReplaceAll(replNewLine, "\\t", "\t")
+	return strings.ReplaceAll(replTab, "\r", "")
+}
+
+// logRecordReader reads the records of a log, the lines not in the unified log format are appended to the previous record.
+type logRecordReader struct {
+	reader *bufio.Reader
+	// next is the first line of the next record.
+	next string
+	eof  bool
+}
+
+func newLogRecordReader(reader io.Reader) *logRecordReader {
+	return &logRecordReader{reader: bufio.NewReader(reader)}
+}
+
+// readLine returns the next line without the line break, io.EOF is returned if there are no more lines.
+// errLogIdle is returned if the followed log has no new line for now.
+func (r *logRecordReader) readLine() (string, error) {
+	if r.eof {
+		return "", io.EOF
+	}
+	line, err := r.reader.ReadString('\n')
+	for err == errLogIdle && len(line) > 0 {
+		// Wait for the rest of the line.
+		var rest string
+		rest, err = r.reader.ReadString('\n')
+		line += rest
+	}
+	if err == errLogIdle {
+		return "", err
+	}
+	if err == io.EOF {
+		r.eof = true
+		if len(line) == 0 {
+			return "", io.EOF
+		}
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Next returns the next record, io.EOF is returned if there are no more records.
+func (r *logRecordReader) Next() (*logRecord, error) {
+	line := r.next
+	r.next = ""
+	if len(line) == 0 {
+		var err error
+		line, err = r.readLine()
+		// There is no record to write out when the followed log is idle.
+		for err == errLogIdle {
+			line, err = r.readLine()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	rec, ok := parseLogRecord(line)
+	if !ok {
+		// It is not a record of the unified log format, such as a panic message.
+		return &logRecord{Message: line, Raw: line}, nil
+	}
+	for {
+		line, err := r.readLine()
+		if err == io.EOF || err == errLogIdle {
+			return rec, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(line, "[") {
+			if _, ok := parseLogRecord(line); ok {
+				r.next = line
+				return rec, nil
+			}
+		}
+		rec.Raw += "\n" + line
+		rec.Message += "\n" + line
+	}
+}
+
+// logFilter filters the log records, the zero value matches all records.
+type logFilter struct {
+	minLevel   int
+	since      time.Time
+	until      time.Time
+	conn       string
+	txnStartTS string
+	message    *regexp.Regexp
+	fields     []logField
+}
+
+// isEmpty returns whether the filter matches all records.
+func (f *logFilter) isEmpty() bool {
+	return f.minLevel == 0 && f.since.IsZero() && f.until.IsZero() && len(f.conn) == 0 &&
+		len(f.txnStartTS) == 0 && f.message == nil && len(f.fields) == 0
+}
+
+func (f *logFilter) match(r *logRecord) bool {
+	if f.minLevel > 0 && logLevels[r.Level] < f.minLevel {
+		return false
+	}
+	if !f.since.IsZero() && (r.Time.IsZero() || r.Time.Before(f.since)) {
+		return false
+	}
+	if !f.until.IsZero() && (r.Time.IsZero() || !r.Time.Before(f.until)) {
+		return false
+	}
+	if len(f.conn) != 0 {
+		if v, ok := r.field(logConnKeys...); !ok || v != f.conn {
+			return false
+		}
+	}
+	if len(f.txnStartTS) != 0 {
+		if v, ok := r.field(logTxnStartTSKeys...); !ok || v != f.txnStartTS {
+			return false
+		}
+	}
+	if f.message != nil && !f.message.MatchString(r.Message) {
+		return false
+	}
+	for _, expected := range f.fields {
+		v, ok := r.field(expected.Key)
+		if !ok || (len(expected.Value) != 0 && v != expected.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// newLogFilter returns the filter of the flags.
+func newLogFilter(level, since, until, conn, txnStartTS, message string, fields []string) (*logFilter, error) {
+	f := &logFilter{conn: conn, txnStartTS: txnStartTS}
+	if len(level) != 0 {
+		f.minLevel = logLevels[strings.ToUpper(level)]
+		if f.minLevel == 0 {
+			return nil, errors.Errorf("invalid level %s, expect one of debug, info, warn, error and fatal", level)
+		}
+	}
+	var err error
+	if len(since) != 0 {
+		if f.since, err = parseTimeFlag(since); err != nil {
+			return nil, err
+		}
+	}
+	if len(until) != 0 {
+		if f.until, err = parseTimeFlag(until); err != nil {
+			return nil, err
+		}
+	}
+	if len(message) != 0 {
+		if f.message, err = regexp.Compile(message); err != nil {
+			return nil, errors.Errorf("invalid message regex %s: %v", message, err)
+		}
+	}
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		expected := logField{Key: kv[0]}
+		if len(kv) == 2 {
+			expected.Value = kv[1]
+		}
+		f.fields = append(f.fields, expected)
+	}
+	return f, nil
+}
+
+// parseTimeFlag parses the time in the local time zone unless the zone is given.
+func parseTimeFlag(s string) (time.Time, error) {
+	layouts := []string{
+		logTimeLayout,
+		time.RFC3339Nano,
+		"2006/01/02 15:04:05.000",
+		"2006/01/02 15:04:05",
+		"2006-01-02 15:04:05.000",
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04:05",
+		"2006-01-02",
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("invalid time %s, expect the form of 2006-01-02 15:04:05", s)
+}
+
+// The output formats of log records.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logRecordWriter writes the log records read from the input.
+type logRecordWriter interface {
+	write(rec *logRecord, input string) error
+}
+
+func newLogRecordWriter(output io.Writer, format string, tagged bool) (logRecordWriter, error) {
+	switch strings.ToLower(format) {
+	case logFormatText:
+		return &textLogWriter{output: output, tagged: tagged}, nil
+	case logFormatJSON:
+		encoder := json.NewEncoder(output)
+		encoder.SetEscapeHTML(false)
+		return &jsonLogWriter{encoder: encoder, tagged: tagged}, nil
+	default:
+		return nil, errors.Errorf("invalid format %s, expect text or json", format)
+	}
+}
+
+// textLogWriter writes the records with the line breaks and tabs unescaped,
+// each line is tagged by the input if the logs are merged.
+type textLogWriter struct {
+	output io.Writer
+	tagged bool
+}
+
+func (w *textLogWriter) write(rec *logRecord, input string) error {
+	text := unescapeLogText(rec.Raw)
+	if w.tagged {
+		tag := "[" + input + "] "
+		text = tag + strings.ReplaceAll(text, "\n", "\n"+tag)
+	}
+	_, err := io.WriteString(w.output, text+"\n")
+	return err
+}
+
+// jsonLogRecord is the JSON form of a log record.
+type jsonLogRecord struct {
+	Time    string            `json:"time"`
+	Level   string            `json:"level"`
+	Source  string            `json:"source"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields"`
+	Input   string            `json:"input,omitempty"`
+}
+
+// jsonLogWriter writes a JSON line for each record, the input is added if the logs are merged.
+type jsonLogWriter struct {
+	encoder *json.Encoder
+	tagged  bool
+}
+
+func (w *jsonLogWriter) write(rec *logRecord, input string) error {
+	jsonRec := &jsonLogRecord{
+		Level:   rec.Level,
+		Source:  rec.Source,
+		Message: rec.Message,
+		Fields:  make(map[string]string, len(rec.Fields)),
+	}
+	if !rec.Time.IsZero() {
+		jsonRec.Time = rec.Time.Format("2006-01-02T15:04:05.000Z07:00")
+	}
+	for _, f := range rec.Fields {
+		jsonRec.Fields[f.Key] = f.Value
+	}
+	if w.tagged {
+		jsonRec.Input = input
+	}
+	return w.encoder.Encode(jsonRec)
+}
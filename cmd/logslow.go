@@ -0,0 +1,337 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser"
+	"github.com/spf13/cobra"
+)
+
+// The keys of the slow log fields.
+const (
+	slowLogTimeKey        = "Time"
+	slowLogTxnStartTSKey  = "Txn_start_ts"
+	slowLogUserKey        = "User"
+	slowLogUserHostKey    = "User@Host"
+	slowLogQueryTimeKey   = "Query_time"
+	slowLogCopTimeKey     = "Cop_time"
+	slowLogProcessTimeKey = "Process_time"
+	slowLogWaitTimeKey    = "Wait_time"
+	slowLogProcessKeysKey = "Process_keys"
+	slowLogMemMaxKey      = "Mem_max"
+	slowLogDigestKey      = "Digest"
+	slowLogPlanKey        = "Plan"
+)
+
+// log slow command flags
+var (
+	slowSince string
+	slowUntil string
+	slowUser  string
+	slowTop   int
+	slowPlan  bool
+)
+
+var logSlowCmd = &cobra.Command{
+	Use:   "slow",
+	Short: "analyze the slow query logs",
+	Long: `Aggregate the slow queries by SQL digest and print the top N digests by total query time, e.g.
+* tidb-ctl log slow /path/to/tidb-slow.log [/path/to/tidb-slow-2020-05-20T10-00-00.000.log.gz] [-n 10]
+* cat tidb-slow.log | tidb-ctl log slow
+* tidb-ctl log slow tidb-slow.log --since "2020-05-20 10:00:00" --until "2020-05-20 11:00:00" --user root`,
+	RunE: analyzeSlowLog,
+}
+
+func init() {
+	logCmd.AddCommand(logSlowCmd)
+	logSlowCmd.Flags().StringVar(&slowSince, "since", "", "only analyze the queries since the time, e.g. \"2020-05-20 10:00:00\"")
+	logSlowCmd.Flags().StringVar(&slowUntil, "until", "", "only analyze the queries before the time")
+	logSlowCmd.Flags().StringVar(&slowUser, "user", "", "only analyze the queries of the user")
+	logSlowCmd.Flags().IntVarP(&slowTop, "top", "n", 10, "the number of digests to print, 0 means all")
+	logSlowCmd.Flags().BoolVar(&slowPlan, "plan", false, "print the plans of the slowest queries of the digests")
+}
+
+// slowQuery is a query in the slow log.
+type slowQuery struct {
+	Time        time.Time
+	TxnStartTS  uint64
+	User        string
+	QueryTime   float64
+	CopTime     float64
+	ProcessKeys int64
+	MemMax      int64
+	Digest      string
+	Plan        string
+	SQL         string
+}
+
+// slowDigest is the statistics of the queries with the same digest.
+type slowDigest struct {
+	digest      string
+	sql         string
+	queryTimes  []float64
+	totalTime   float64
+	copTime     float64
+	processKeys int64
+	memMax      int64
+	// slowest is the query with the max query time.
+	slowest *slowQuery
+}
+
+func (d *slowDigest) p99() float64 {
+	sort.Float64s(d.queryTimes)
+	i := int(math.Ceil(float64(len(d.queryTimes))*0.99)) - 1
+	if i < 0 {
+		i = 0
+	}
+	return d.queryTimes[i]
+}
+
+func analyzeSlowLog(c *cobra.Command, args []string) error {
+	logStdin = c.InOrStdin()
+	if len(args) == 0 {
+		args = []string{stdinPath}
+	}
+	var since, until time.Time
+	var err error
+	if len(slowSince) != 0 {
+		if since, err = parseTimeFlag(slowSince); err != nil {
+			return err
+		}
+	}
+	if len(slowUntil) != 0 {
+		if until, err = parseTimeFlag(slowUntil); err != nil {
+			return err
+		}
+	}
+	digests := make(map[string]*slowDigest)
+	var order []*slowDigest
+	queries := 0
+	collect := func(q *slowQuery) {
+		if (!since.IsZero() && q.Time.Before(since)) || (!until.IsZero() && !q.Time.Before(until)) {
+			return
+		}
+		if len(slowUser) != 0 && q.User != slowUser {
+			return
+		}
+		queries++
+		normalized, digest := parser.NormalizeDigest(q.SQL)
+		if len(q.Digest) != 0 {
+			digest = q.Digest
+		}
+		d, ok := digests[digest]
+		if !ok {
+			d = &slowDigest{digest: digest, sql: normalized}
+			digests[digest] = d
+			order = append(order, d)
+		}
+		d.queryTimes = append(d.queryTimes, q.QueryTime)
+		d.totalTime += q.QueryTime
+		d.copTime += q.CopTime
+		d.processKeys += q.ProcessKeys
+		if q.MemMax > d.memMax {
+			d.memMax = q.MemMax
+		}
+		if d.slowest == nil || q.QueryTime > d.slowest.QueryTime {
+			d.slowest = q
+		}
+	}
+	for _, path := range args {
+		input, err := openLogInput(path)
+		if err != nil {
+			return err
+		}
+		err = readSlowLog(input, collect)
+		if closeErr := input.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool { return order[i].totalTime > order[j].totalTime })
+	c.Printf("%d queries, %d digests\n", queries, len(order))
+	if slowTop > 0 && len(order) > slowTop {
+		order = order[:slowTop]
+	}
+	var rows [][]string
+	for i, d := range order {
+		digest := d.digest
+		if len(digest) > 16 {
+			digest = digest[:16]
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(i + 1),
+			digest,
+			strconv.Itoa(len(d.queryTimes)),
+			formatSeconds(d.totalTime),
+			formatSeconds(d.totalTime / float64(len(d.queryTimes))),
+			formatSeconds(d.p99()),
+			formatSeconds(d.copTime),
+			strconv.FormatInt(d.processKeys, 10),
+			humanizeBytes(float64(d.memMax)),
+			strconv.FormatUint(d.slowest.TxnStartTS, 10),
+			d.sql,
+		})
+	}
+	printTable(c, []string{"RANK", "DIGEST", "COUNT", "TOTAL", "AVG", "P99", "COP_TIME", "PROCESS_KEYS", "MEM_MAX", "SLOWEST_TXN_START_TS", "SQL"}, rows)
+	if slowPlan {
+		for i, d := range order {
+			if len(d.slowest.Plan) == 0 {
+				continue
+			}
+			c.Printf("plan of the slowest query of rank %d (%s at %s):\n%s\n", i+1, formatSeconds(d.slowest.QueryTime),
+				d.slowest.Time.Format("2006-01-02 15:04:05.000"), d.slowest.Plan)
+		}
+	}
+	return nil
+}
+
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 3, 64) + "s"
+}
+
+// readSlowLog reads the queries of the slow log and calls collect for each query.
+func readSlowLog(input io.Reader, collect func(*slowQuery)) error {
+	reader := bufio.NewReader(input)
+	var (
+		q   *slowQuery
+		sql []string
+	)
+	finish := func() {
+		if q == nil {
+			return
+		}
+		// The SQL may be led by a `use db;` statement.
+		if len(sql) > 1 && strings.HasPrefix(strings.ToLower(sql[0]), "use ") {
+			sql = sql[1:]
+		}
+		// The SQL is ended with a `;` in the slow log.
+		q.SQL = strings.TrimSuffix(strings.Join(sql, "\n"), ";")
+		collect(q)
+		q, sql = nil, nil
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "# ") {
+			fields := parseSlowLogFields(line[2:])
+			if value, ok := fields[slowLogTimeKey]; ok {
+				finish()
+				t, parseErr := time.Parse(time.RFC3339Nano, value)
+				if parseErr != nil {
+					return errors.Errorf("invalid slow log time %s: %v", value, parseErr)
+				}
+				q = &slowQuery{Time: t}
+			}
+			if q != nil {
+				q.setFields(fields)
+			}
+		} else if q != nil && len(line) != 0 {
+			sql = append(sql, line)
+		}
+		if err == io.EOF {
+			finish()
+			return nil
+		}
+	}
+}
+
+// parseSlowLogFields parses the `Key: value Key2: value2` in a slow log line.
+func parseSlowLogFields(line string) map[string]string {
+	fields := make(map[string]string)
+	tokens := strings.Fields(line)
+	for i := 0; i < len(tokens); i++ {
+		if !strings.HasSuffix(tokens[i], ":") {
+			continue
+		}
+		key := strings.TrimSuffix(tokens[i], ":")
+		if key == slowLogPlanKey || key == slowLogUserHostKey {
+			// The values may contain spaces.
+			fields[key] = strings.Join(tokens[i+1:], " ")
+			return fields
+		}
+		if i+1 < len(tokens) {
+			fields[key] = tokens[i+1]
+			i++
+		}
+	}
+	return fields
+}
+
+func (q *slowQuery) setFields(fields map[string]string) {
+	parseFloat := func(key string) float64 {
+		v, err := strconv.ParseFloat(fields[key], 64)
+		if err != nil {
+			return 0
+		}
+		return v
+	}
+	parseUint := func(key string) uint64 {
+		v, err := strconv.ParseUint(fields[key], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return v
+	}
+	parseInt := func(key string) int64 {
+		v, err := strconv.ParseInt(fields[key], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return v
+	}
+	for key, value := range fields {
+		switch key {
+		case slowLogTxnStartTSKey:
+			q.TxnStartTS = parseUint(key)
+		case slowLogUserKey:
+			// It is in the form of user@host.
+			q.User = strings.SplitN(value, "@", 2)[0]
+		case slowLogUserHostKey:
+			// It is in the form of user[user] @ host [host].
+			q.User = strings.SplitN(value, "[", 2)[0]
+		case slowLogQueryTimeKey:
+			q.QueryTime = parseFloat(key)
+		case slowLogCopTimeKey:
+			q.CopTime = parseFloat(key)
+		case slowLogProcessTimeKey, slowLogWaitTimeKey:
+			// The cop time is the sum of the process time and the wait time if it is not logged.
+			if _, ok := fields[slowLogCopTimeKey]; !ok {
+				q.CopTime += parseFloat(key)
+			}
+		case slowLogProcessKeysKey:
+			q.ProcessKeys = parseInt(key)
+		case slowLogMemMaxKey:
+			q.MemMax = parseInt(key)
+		case slowLogDigestKey:
+			q.Digest = value
+		case slowLogPlanKey:
+			q.Plan = value
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// log summary command flags
+var (
+	summarySince string
+	summaryUntil string
+	summaryTop   int
+)
+
+var logSummaryCmd = &cobra.Command{
+	Use:   "summary",
+	Short: "summarize the errors, warnings and critical events of the logs",
+	Long: `Group the ERROR and WARN records by message template, in which the numbers, keys and IDs are masked,
+count each template per log, and list the critical events with the first and last seen time, e.g.
+* tidb-ctl log summary tidb1/tidb.log tidb2/tidb.log [-n 20]
+* tidb-ctl log summary tidb.log --since "2020-05-20 10:00:00" --until "2020-05-20 11:00:00"`,
+	RunE: summarizeLog,
+}
+
+func init() {
+	logCmd.AddCommand(logSummaryCmd)
+	logSummaryCmd.Flags().StringVar(&summarySince, "since", "", "only summarize the records since the time, e.g. \"2020-05-20 10:00:00\"")
+	logSummaryCmd.Flags().StringVar(&summaryUntil, "until", "", "only summarize the records before the time")
+	logSummaryCmd.Flags().IntVarP(&summaryTop, "top", "n", 20, "the number of templates to print, 0 means all")
+}
+
+// logErrorKeys are the field keys of the errors in TiDB logs, the errors are a part of the templates.
+var logErrorKeys = []string{"error", "err"}
+
+// logMasks replace the variable parts of the messages, in order.
+var logMasks = []struct {
+	pattern *regexp.Regexp
+	repl    string
+}{
+	{regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`), "<uuid>"},
+	// The keys are printed in hex, or escaped like t\x80\x00\x00\x00\x00\x00\x00\x2d_r.
+	{regexp.MustCompile(`[^\s,:;=()\[\]{}"']*\\x[0-9a-fA-F]{2}[^\s,:;=()\[\]{}"']*`), "<key>"},
+	{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`), "<hex>"},
+	// The long decimal numbers, such as timestamps, are not keys.
+	{regexp.MustCompile(`\b\d{16,}\b`), "<num>"},
+	{regexp.MustCompile(`\b[0-9a-fA-F]{16,}\b`), "<key>"},
+	// The numbers with units or dots, such as 10ms, 1.5s and 127.0.0.1.
+	{regexp.MustCompile(`\b\d[\w.]*`), "<num>"},
+}
+
+// logCriticalEvents are the events which usually lead to errors of the cluster.
+var logCriticalEvents = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"DDL owner change", regexp.MustCompile(`(?i)(become|campaign|retire|resign)\w*\b.*\bowner|owner\b.*\b(change|retire|resign|lost)`)},
+	{"schema lease expiry", regexp.MustCompile(`(?i)schema.*(lease.*expire|expire.*lease|out of date)`)},
+	{"region miss", regexp.MustCompile(`(?i)region ?miss|region not found|epoch ?not ?match`)},
+	{"write conflict", regexp.MustCompile(`(?i)write ?conflict`)},
+	{"OOM action", regexp.MustCompile(`(?i)\boom\b|out of memory|exceeds? (the )?(memory )?quota`)},
+}
+
+// logTemplate is the statistics of the records with the same level and message template.
+type logTemplate struct {
+	level    string
+	template string
+	count    int
+	// counts are the counts of the template in each log.
+	counts []int
+}
+
+// logEvent is the statistics of a critical event.
+type logEvent struct {
+	name      string
+	count     int
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// maskLogText replaces the numbers, keys and IDs in the text.
+func maskLogText(s string) string {
+	for _, m := range logMasks {
+		s = m.pattern.ReplaceAllString(s, m.repl)
+	}
+	return s
+}
+
+// firstLine returns the first line of the text.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// logRecordTemplate returns the template of the message and the error of the record.
+func logRecordTemplate(rec *logRecord) string {
+	template := maskLogText(firstLine(rec.Message))
+	if err, ok := rec.field(logErrorKeys...); ok {
+		template += " error=" + maskLogText(firstLine(unescapeLogText(err)))
+	}
+	return template
+}
+
+func summarizeLog(c *cobra.Command, args []string) error {
+	logStdin = c.InOrStdin()
+	if len(args) == 0 {
+		args = []string{stdinPath}
+	}
+	filter, err := newLogFilter("", summarySince, summaryUntil, "", "", "", nil)
+	if err != nil {
+		return err
+	}
+	templates := make(map[string]*logTemplate)
+	var order []*logTemplate
+	events := make(map[string]*logEvent)
+	records := 0
+	for i, path := range args {
+		input, err := openLogInput(path)
+		if err != nil {
+			return err
+		}
+		err = readLogRecords(input, func(rec *logRecord) {
+			if len(rec.Level) == 0 || !filter.match(rec) {
+				return
+			}
+			for _, e := range logCriticalEvents {
+				if !e.pattern.MatchString(rec.Raw) {
+					continue
+				}
+				event, ok := events[e.name]
+				if !ok {
+					event = &logEvent{name: e.name, firstSeen: rec.Time}
+					events[e.name] = event
+				}
+				event.count++
+				if rec.Time.Before(event.firstSeen) {
+					event.firstSeen = rec.Time
+				}
+				if rec.Time.After(event.lastSeen) {
+					event.lastSeen = rec.Time
+				}
+			}
+			if logLevels[rec.Level] < logLevels["WARN"] {
+				return
+			}
+			records++
+			template := logRecordTemplate(rec)
+			key := rec.Level + " " + template
+			t, ok := templates[key]
+			if !ok {
+				t = &logTemplate{level: rec.Level, template: template, counts: make([]int, len(args))}
+				templates[key] = t
+				order = append(order, t)
+			}
+			t.count++
+			t.counts[i]++
+		})
+		if closeErr := input.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		if logLevels[order[i].level] != logLevels[order[j].level] {
+			return logLevels[order[i].level] > logLevels[order[j].level]
+		}
+		return order[i].count > order[j].count
+	})
+	c.Printf("%d error and warning records, %d templates\n", records, len(order))
+	if summaryTop > 0 && len(order) > summaryTop {
+		order = order[:summaryTop]
+	}
+	header := []string{"LEVEL", "COUNT"}
+	header = append(header, args...)
+	header = append(header, "TEMPLATE")
+	var rows [][]string
+	for _, t := range order {
+		row := []string{t.level, strconv.Itoa(t.count)}
+		for _, count := range t.counts {
+			row = append(row, strconv.Itoa(count))
+		}
+		rows = append(rows, append(row, t.template))
+	}
+	printTable(c, header, rows)
+
+	c.Println()
+	if len(events) == 0 {
+		c.Println("no critical events")
+		return nil
+	}
+	c.Println("critical events:")
+	rows = nil
+	for _, e := range logCriticalEvents {
+		event, ok := events[e.name]
+		if !ok {
+			continue
+		}
+		rows = append(rows, []string{event.name, strconv.Itoa(event.count),
+			event.firstSeen.Format(logTimeLayout), event.lastSeen.Format(logTimeLayout)})
+	}
+	printTable(c, []string{"EVENT", "COUNT", "FIRST_SEEN", "LAST_SEEN"}, rows)
+	return nil
+}
+
+// readLogRecords reads the records of the log and calls collect for each record.
+func readLogRecords(input io.Reader, collect func(*logRecord)) error {
+	reader := newLogRecordReader(input)
+	for {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		collect(rec)
+	}
+}
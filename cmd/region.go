@@ -14,50 +14,241 @@
 package cmd
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
 	"github.com/spf13/cobra"
 )
 
 const (
 	regionPrefix     = "regions/"
+	dbTablePrefix    = "db-table/"
+	pdRegionKeyPath  = "/pd/api/v1/region/key/"
 	metaFlagName     = "meta"
 	regionIDFlagName = "rid"
+	regionKeyName    = "key"
 )
 
 var (
-	isMeta   bool
-	regionID uint64
+	isMeta    bool
+	regionID  uint64
+	regionKey string
 )
 
 // regionCmd represents the region command
 var regionRootCmd = &cobra.Command{
 	Use:   "region",
 	Short: "Region information",
-	Long: `tidb-ctl region --meta(-m) | --rid(-i) [region id]
+	Long: `tidb-ctl region --meta(-m) | --rid(-i) [region id] | --key(-k) [key]
 * --meta will return region info where meta data located
-* --rid will return region info by region id`,
+* --rid will return region info by region id
+* --key will return region info of the region containing the key,
+  the key is in hex format like the key in MVCC info, or in the format of 'tidb-ctl decoder'`,
 	RunE: getRegionInfo,
 }
 
+// regionDetail is the region info returned by the TiDB status port.
+type regionDetail struct {
+	RegionID uint64          `json:"region_id"`
+	StartKey []byte          `json:"start_key"`
+	EndKey   []byte          `json:"end_key"`
+	Frames   json.RawMessage `json:"frames"`
+}
+
+// decodedRegion is the region info with decoded start key and end key.
+type decodedRegion struct {
+	RegionID        uint64          `json:"region_id"`
+	StartKey        string          `json:"start_key"`
+	EndKey          string          `json:"end_key"`
+	DecodedStartKey *keyInfo        `json:"decoded_start_key"`
+	DecodedEndKey   *keyInfo        `json:"decoded_end_key"`
+	Frames          json.RawMessage `json:"frames"`
+}
+
+// dbTableInfo is the response of the db-table API of the TiDB status port.
+type dbTableInfo struct {
+	DBInfo    *model.DBInfo    `json:"db_info"`
+	TableInfo *model.TableInfo `json:"table_info"`
+}
+
 func init() {
 	regionRootCmd.Flags().BoolVarP(&isMeta, metaFlagName, "m", false, "region info where meta data located")
 	regionRootCmd.Flags().Uint64VarP(&regionID, regionIDFlagName, "i", 0, "region id")
+	regionRootCmd.Flags().StringVarP(&regionKey, regionKeyName, "k", "", "find the region containing the key by PD")
 }
 
 func getRegionInfo(c *cobra.Command, args []string) error {
 	if len(args) != 0 {
 		return fmt.Errorf("too many arguments")
 	}
-	if c.Flag(metaFlagName).Changed && c.Flag(regionIDFlagName).Changed {
-		return fmt.Errorf("%s and %s can not be set simultaneously", metaFlagName, regionIDFlagName)
+	changed := 0
+	for _, name := range []string{metaFlagName, regionIDFlagName, regionKeyName} {
+		if c.Flag(name).Changed {
+			changed++
+		}
+	}
+	if changed > 1 {
+		return fmt.Errorf("%s, %s and %s can not be set simultaneously", metaFlagName, regionIDFlagName, regionKeyName)
 	}
 	if c.Flag(metaFlagName).Changed {
 		return httpPrint(regionPrefix + "meta")
 	}
 	if c.Flag(regionIDFlagName).Changed {
-		return httpPrint(regionPrefix + strconv.FormatUint(regionID, 10))
+		return printDecodedRegion(c, regionID)
+	}
+	if c.Flag(regionKeyName).Changed {
+		id, err := getRegionIDByKey(regionKey)
+		if err != nil {
+			return err
+		}
+		return printDecodedRegion(c, id)
 	}
 	return c.Usage()
 }
+
+func printDecodedRegion(c *cobra.Command, id uint64) error {
+	body, status, err := httpGet(regionPrefix + strconv.FormatUint(id, 10))
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		// Print response body directly if status is not ok.
+		c.Println(string(body))
+		return nil
+	}
+	var region regionDetail
+	if err = json.Unmarshal(body, &region); err != nil {
+		return err
+	}
+	res := &decodedRegion{
+		RegionID:        region.RegionID,
+		StartKey:        strings.ToUpper(hex.EncodeToString(region.StartKey)),
+		EndKey:          strings.ToUpper(hex.EncodeToString(region.EndKey)),
+		DecodedStartKey: decodeKeyPrefix(region.StartKey),
+		DecodedEndKey:   decodeKeyPrefix(region.EndKey),
+		Frames:          region.Frames,
+	}
+	tables := make(map[int64]*dbTableInfo)
+	if err = fillKeyNames(res.DecodedStartKey, tables); err != nil {
+		return err
+	}
+	if err = fillKeyNames(res.DecodedEndKey, tables); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(res, "", "    ")
+	if err != nil {
+		return err
+	}
+	c.Println(string(out))
+	return nil
+}
+
+// fillKeyNames fills the database, table, partition and index names of a decoded key.
+// tables caches the table infos by physical table ID.
+func fillKeyNames(k *keyInfo, tables map[int64]*dbTableInfo) error {
+	if k.TableID == 0 {
+		return nil
+	}
+	info, ok := tables[k.TableID]
+	if !ok {
+		var err error
+		if info, err = getDBTableInfo(k.TableID); err != nil {
+			return err
+		}
+		tables[k.TableID] = info
+	}
+	if info == nil || info.TableInfo == nil {
+		return nil
+	}
+	if info.DBInfo != nil {
+		k.DBName = info.DBInfo.Name.O
+	}
+	k.TableName = info.TableInfo.Name.O
+	if pi := info.TableInfo.Partition; pi != nil && info.TableInfo.ID != k.TableID {
+		for _, def := range pi.Definitions {
+			if def.ID == k.TableID {
+				k.PartitionName = def.Name.O
+				break
+			}
+		}
+	}
+	if k.Type == keyTypeIndex {
+		for _, idx := range info.TableInfo.Indices {
+			if idx.ID == k.IndexID {
+				k.IndexName = idx.Name.O
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// getDBTableInfo returns the infos of the database and table by physical table ID,
+// it returns nil if the table is not found, e.g. the table has been dropped.
+func getDBTableInfo(tableID int64) (*dbTableInfo, error) {
+	if len(schemaFile) != 0 {
+		snap, err := getSchemaSnapshot()
+		if err != nil {
+			return nil, err
+		}
+		dbInfo, tblInfo, err := snap.findTableByID(tableID)
+		if err != nil {
+			return nil, nil
+		}
+		return &dbTableInfo{DBInfo: dbInfo, TableInfo: tblInfo}, nil
+	}
+	body, status, err := httpGet(dbTablePrefix + strconv.FormatInt(tableID, 10))
+	if err != nil || status != http.StatusOK {
+		return nil, err
+	}
+	info := &dbTableInfo{}
+	if err = json.Unmarshal(body, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// parseKey parses a raw key in hex format or in the format of 'tidb-ctl decoder'.
+func parseKey(key string) ([]byte, error) {
+	if raw, err := hex.DecodeString(key); err == nil {
+		return raw, nil
+	}
+	raw, err := decodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(raw), nil
+}
+
+// getRegionIDByKey asks PD for the region containing the raw key.
+func getRegionIDByKey(key string) (uint64, error) {
+	raw, err := parseKey(key)
+	if err != nil {
+		return 0, err
+	}
+	req, err := getRequest(pdRegionKeyPath+url.QueryEscape(string(encodeBytes(raw))), http.MethodGet, "application/json", nil)
+	if err != nil {
+		return 0, err
+	}
+	res, err := dial(req)
+	if err != nil {
+		return 0, err
+	}
+	var region struct {
+		ID uint64 `json:"id"`
+	}
+	if err = json.Unmarshal([]byte(res), &region); err != nil {
+		return 0, err
+	}
+	if region.ID == 0 {
+		return 0, errors.Errorf("region of key %s is not found", key)
+	}
+	return region.ID, nil
+}
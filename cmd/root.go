@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/spf13/cobra"
@@ -42,6 +43,21 @@ var (
 	sslKey    string
 	ctlClient *http.Client
 	schema    string
+	// tlsServerName is the server name to verify the certificate of TiDB server.
+	tlsServerName      string
+	insecureSkipVerify bool
+	tlsMinVersion      string
+	// The TLS options of PD server, the CA, cert and key are the same as TiDB server's if they are not set.
+	// pdTLS turns TLS of PD server on or off, or follows the TLS options if it is auto.
+	pdTLS           = pdTLSAuto
+	pdCA            string
+	pdSSLCert       string
+	pdSSLKey        string
+	pdTLSServerName string
+	pdClient        *http.Client
+	pdSchema        string
+	// schemaFile is the schema snapshot used instead of the schema of TiDB server.
+	schemaFile string
 )
 
 const (
@@ -58,6 +74,8 @@ var rootCmd = &cobra.Command{
 	Short: rootShort,
 	Long:  rootLong,
 	RunE:  genDocument,
+	// The subcommands with their own PersistentPreRunE call setupCommand since only the nearest one is run.
+	PersistentPreRunE: setupCommand,
 }
 
 func genDocument(c *cobra.Command, args []string) error {
@@ -70,7 +88,7 @@ func genDocument(c *cobra.Command, args []string) error {
 		Short: rootShort,
 		Long:  rootLong,
 	}
-	docCmd.AddCommand(mvccRootCmd, schemaRootCmd, regionRootCmd, tableRootCmd, decoderCmd, newBase64decodeCmd, newEtcdCommand(), keyRangeCmd)
+	docCmd.AddCommand(mvccRootCmd, schemaRootCmd, regionRootCmd, tableRootCmd, decoderCmd, newBase64decodeCmd, newEtcdCommand(), keyRangeCmd, lockRootCmd, diskUsageRootCmd)
 	fmt.Println("Generating documents...")
 	if err := doc.GenMarkdownTree(docCmd, docDir); err != nil {
 		return err
@@ -89,8 +107,17 @@ func Execute() {
 }
 
 func httpGet(path string) (body []byte, status int, err error) {
-	url := schema + "://" + host.String() + ":" + strconv.Itoa(int(port)) + "/" + path
-	resp, err := ctlClient.Get(url)
+	return httpGetFrom(net.JoinHostPort(host.String(), strconv.Itoa(int(port))), path)
+}
+
+// httpGetFrom gets the path from the TiDB server with the status address addr.
+func httpGetFrom(addr string, path string) (body []byte, status int, err error) {
+	req, err := http.NewRequest(http.MethodGet, schema+"://"+addr+"/"+path, nil)
+	if err != nil {
+		return
+	}
+	req = withTimeout(req)
+	resp, err := ctlClient.Do(req)
 	if err != nil {
 		return
 	}
@@ -104,6 +131,23 @@ func httpGet(path string) (body []byte, status int, err error) {
 	return
 }
 
+// httpGetJSON gets the path from the TiDB server and unmarshals the response into v.
+func httpGetJSON(path string, v interface{}) error {
+	return httpGetJSONFrom(net.JoinHostPort(host.String(), strconv.Itoa(int(port))), path, v)
+}
+
+// httpGetJSONFrom gets the path from the TiDB server with the status address addr and unmarshals the response into v.
+func httpGetJSONFrom(addr string, path string, v interface{}) error {
+	body, status, err := httpGetFrom(addr, path)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return httpStatusError(schema+"://"+addr+"/"+path, status, body)
+	}
+	return json.Unmarshal(body, v)
+}
+
 func httpPrint(path string) error {
 	body, status, err := httpGet(path)
 	if err != nil {
@@ -132,10 +176,27 @@ const (
 	caName         = "ca"
 	sslKeyName     = "ssl-key"
 	sslCertName    = "ssl-cert"
+	schemaFileName = "schema-file"
+
+	tlsServerNameName      = "tls-server-name"
+	insecureSkipVerifyName = "insecure-skip-verify"
+	tlsMinVersionName      = "tls-min-version"
+	pdCAName               = "pd-ca"
+	pdSSLKeyName           = "pd-ssl-key"
+	pdSSLCertName          = "pd-ssl-cert"
+	pdTLSServerNameName    = "pd-tls-server-name"
+	pdTLSName              = "pd-tls"
+)
+
+// The values of --pd-tls.
+const (
+	pdTLSAuto = "auto"
+	pdTLSOn   = "on"
+	pdTLSOff  = "off"
 )
 
 func init() {
-	rootCmd.AddCommand(mvccRootCmd, schemaRootCmd, regionRootCmd, tableRootCmd, newBase64decodeCmd, decoderCmd, logCmd, newEtcdCommand(), keyRangeCmd)
+	rootCmd.AddCommand(mvccRootCmd, schemaRootCmd, regionRootCmd, tableRootCmd, newBase64decodeCmd, decoderCmd, logCmd, newEtcdCommand(), keyRangeCmd, lockRootCmd, diskUsageRootCmd)
 
 	rootCmd.PersistentFlags().IPVarP(&host, hostFlagName, "", net.ParseIP("127.0.0.1"), "TiDB server host")
 	rootCmd.PersistentFlags().Uint16VarP(&port, portFlagName, "", 10080, "TiDB server port")
@@ -144,54 +205,139 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&ca, caName, "", "", "TLS CA path")
 	rootCmd.PersistentFlags().StringVarP(&sslKey, sslKeyName, "", "", "TLS Key path")
 	rootCmd.PersistentFlags().StringVarP(&sslCert, sslCertName, "", "", "TLS Cert path")
+	rootCmd.PersistentFlags().StringVar(&tlsServerName, tlsServerNameName, "", "the server name to verify the TLS certificate of TiDB server, the host by default")
+	rootCmd.PersistentFlags().BoolVar(&insecureSkipVerify, insecureSkipVerifyName, false, "do not verify the TLS certificates of TiDB and PD servers if TLS is on, which is insecure")
+	rootCmd.PersistentFlags().StringVar(&tlsMinVersion, tlsMinVersionName, "", "the minimum TLS version if TLS is on: 1.0, 1.1, 1.2 or 1.3")
+	rootCmd.PersistentFlags().StringVar(&pdTLS, pdTLSName, pdTLSAuto, "whether to connect to PD server by TLS: auto, on or off, auto means TLS is on if the CA, cert or key of PD or TiDB server is set")
+	rootCmd.PersistentFlags().StringVar(&pdCA, pdCAName, "", "TLS CA path of PD server, --ca by default")
+	rootCmd.PersistentFlags().StringVar(&pdSSLKey, pdSSLKeyName, "", "TLS Key path of PD server, --ssl-key by default")
+	rootCmd.PersistentFlags().StringVar(&pdSSLCert, pdSSLCertName, "", "TLS Cert path of PD server, --ssl-cert by default")
+	rootCmd.PersistentFlags().StringVar(&pdTLSServerName, pdTLSServerNameName, "", "the server name to verify the TLS certificate of PD server, the PD host by default")
+	rootCmd.PersistentFlags().DurationVar(&httpTimeout, timeoutFlagName, 30*time.Second, "timeout of each attempt of the requests to TiDB and PD servers, only connecting, TLS handshake and waiting for the response are limited for etcd watch, 0 means no timeout")
+	rootCmd.PersistentFlags().IntVar(&httpRetries, retriesFlagName, 2, "retries of the GET requests failed on the network or with a gateway error")
+	rootCmd.PersistentFlags().StringVarP(&schemaFile, schemaFileName, "", "", "schema snapshot file saved by `tidb-ctl schema export`, used instead of the schema of TiDB server")
 	rootCmd.Flags().BoolVar(&genDoc, docFlagName, false, "generate doc file")
 	if err := rootCmd.Flags().MarkHidden(docFlagName); err != nil {
 		fmt.Printf("can not mark hidden flag, flag %s is not found", docFlagName)
 		return
 	}
-	cobra.OnInitialize(func() {
-		tlsConfig, err := prepareTLSConfig()
-		if err != nil {
-			fmt.Printf("cannot setup tls: %v", err)
-		}
-		if tlsConfig != nil {
-			schema = "https"
-		} else {
-			schema = "http"
+}
+
+// setupCommand sets up the HTTP clients before running a command.
+func setupCommand(*cobra.Command, []string) error {
+	if err := setupHTTPClients(); err != nil {
+		return errors.Errorf("cannot setup tls: %v", err)
+	}
+	return nil
+}
+
+// tlsOptions are the TLS options of a server.
+type tlsOptions struct {
+	ca         string
+	sslCert    string
+	sslKey     string
+	serverName string
+}
+
+// orDefault returns the options with the unset CA, cert and key taken from def.
+// The server name is not taken since it is for the certificate of another server.
+func (o tlsOptions) orDefault(def tlsOptions) tlsOptions {
+	if len(o.ca) == 0 {
+		o.ca = def.ca
+	}
+	if len(o.sslCert) == 0 && len(o.sslKey) == 0 {
+		o.sslCert, o.sslKey = def.sslCert, def.sslKey
+	}
+	return o
+}
+
+// enablesTLS returns whether the options turn TLS on, the server name only modifies the verification.
+func (o tlsOptions) enablesTLS() bool {
+	return len(o.ca) != 0 || len(o.sslCert) != 0 || len(o.sslKey) != 0
+}
+
+func (o tlsOptions) isEmpty() bool {
+	return len(o.ca) == 0 && len(o.sslCert) == 0 && len(o.sslKey) == 0 && len(o.serverName) == 0
+}
+
+// setupHTTPClients sets up the clients and the schemas of TiDB server and PD server.
+func setupHTTPClients() error {
+	tidbOptions := tlsOptions{ca: ca, sslCert: sslCert, sslKey: sslKey, serverName: tlsServerName}
+	pdOptions := tlsOptions{ca: pdCA, sslCert: pdSSLCert, sslKey: pdSSLKey, serverName: pdTLSServerName}
+	tlsConfig, err := prepareTLSConfig(tidbOptions, false)
+	if err != nil {
+		return err
+	}
+	var pdTLSConfig *tls.Config
+	switch pdTLS {
+	case pdTLSAuto, pdTLSOn:
+		if pdTLSConfig, err = prepareTLSConfig(pdOptions.orDefault(tidbOptions), pdTLS == pdTLSOn); err != nil {
+			return errors.Errorf("PD: %v", err)
 		}
-		ctlClient = &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: tlsConfig,
-			},
+	case pdTLSOff:
+		if !pdOptions.isEmpty() {
+			return errors.Errorf("PD: the TLS options of PD server are set but --%s is %s", pdTLSName, pdTLSOff)
 		}
-	})
+	default:
+		return errors.Errorf("invalid --%s %s, expect one of auto, on and off", pdTLSName, pdTLS)
+	}
+	schema, ctlClient = httpSchema(tlsConfig), newHTTPClient(tlsConfig)
+	pdSchema, pdClient = httpSchema(pdTLSConfig), newHTTPClient(pdTLSConfig)
+	return nil
+}
+
+func httpSchema(tlsConfig *tls.Config) string {
+	if tlsConfig != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// tlsVersions are the values of --tls-min-version.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
 }
 
-func prepareTLSConfig() (tlsConfig *tls.Config, err error) {
-	if len(ca) != 0 {
-		tlsConfig = &tls.Config{}
+// prepareTLSConfig returns the TLS config of the options, nil is returned if TLS is not enabled by the options or force.
+// --insecure-skip-verify and --tls-min-version only modify the config, they do not enable TLS.
+func prepareTLSConfig(opts tlsOptions, force bool) (tlsConfig *tls.Config, err error) {
+	var minVersion uint16
+	if len(tlsMinVersion) != 0 {
+		var ok bool
+		if minVersion, ok = tlsVersions[tlsMinVersion]; !ok {
+			return nil, errors.Errorf("invalid TLS version %s, expect one of 1.0, 1.1, 1.2 and 1.3", tlsMinVersion)
+		}
+	}
+	if !force && !opts.enablesTLS() {
+		return nil, nil
+	}
+	tlsConfig = &tls.Config{ServerName: opts.serverName, InsecureSkipVerify: insecureSkipVerify, MinVersion: minVersion}
+	if len(opts.ca) != 0 {
 		certPool := x509.NewCertPool()
 		// Create a certificate pool from the certificate authority
 		var caBytes []byte
-		caBytes, err = ioutil.ReadFile(ca)
+		caBytes, err = ioutil.ReadFile(opts.ca)
 		if err != nil {
 			err = errors.Errorf("could not read ca certificate: %s", err)
 			return
 		}
 		// Append the certificates from the CA
 		if !certPool.AppendCertsFromPEM(caBytes) {
-			err = errors.New("failed to append ca certs")
+			err = errors.Errorf("failed to append ca certs from %s", opts.ca)
 			return
 		}
 		tlsConfig.RootCAs = certPool
 	}
-	if len(sslCert) != 0 && len(sslKey) != 0 {
-		if tlsConfig == nil {
-			tlsConfig = &tls.Config{}
-		}
+	if (len(opts.sslCert) == 0) != (len(opts.sslKey) == 0) {
+		return nil, errors.New("the TLS cert and key should be set together")
+	}
+	if len(opts.sslCert) != 0 {
 		getCert := func() (*tls.Certificate, error) {
 			// Load the client certificates from disk
-			cert, err := tls.LoadX509KeyPair(sslCert, sslKey)
+			cert, err := tls.LoadX509KeyPair(opts.sslCert, opts.sslKey)
 			if err != nil {
 				return nil, errors.Errorf("could not load client key pair: %s", err)
 			}
@@ -14,9 +14,11 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 
+	"github.com/pingcap/parser/model"
 	"github.com/spf13/cobra"
 )
 
@@ -30,6 +32,7 @@ const (
 var (
 	schemaTable string
 	schemaTID   int64
+	schemaDBID  int64
 )
 
 // schemaRootCmd represents the schema command
@@ -50,7 +53,7 @@ func listDatabases(_ *cobra.Command, args []string) error {
 func init() {
 	idFlagName := "id"
 
-	schemaRootCmd.AddCommand(listTableByNameCmd, listTableByIDCmd)
+	schemaRootCmd.AddCommand(listTableByNameCmd, listTableByIDCmd, listDatabaseByIDCmd)
 
 	listTableByNameCmd.Flags().StringVarP(&schemaTable, "name", "n", "", "get schema info of a specified table.")
 	listTableByIDCmd.Flags().Int64VarP(&schemaTID, idFlagName, "i", 0, "get schema info of a specified table id.")
@@ -59,6 +62,11 @@ func init() {
 		fmt.Printf("can not mark required flag, flag %s is not found", idFlagName)
 		return
 	}
+	listDatabaseByIDCmd.Flags().Int64VarP(&schemaDBID, idFlagName, "i", 0, "get schema info of a specified database id.")
+	if err := listDatabaseByIDCmd.MarkFlagRequired(idFlagName); err != nil {
+		fmt.Printf("can not mark required flag, flag %s is not found", idFlagName)
+		return
+	}
 }
 
 // listTableByNameCmd represents the list table schema by name command
@@ -97,3 +105,76 @@ func listTableByID(_ *cobra.Command, args []string) error {
 	}
 	return httpPrint(tableIDPrefix + strconv.FormatInt(schemaTID, 10))
 }
+
+var listDatabaseByIDCmd = &cobra.Command{
+	Use:   "dbid",
+	Short: "Schema Information of Database By DatabaseID",
+	Long:  "'tidb-ctl schema dbid --id(-i) [databaseID]' to get schema info of a specified database id.",
+	RunE:  listDatabaseByID,
+}
+
+func listDatabaseByID(c *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("too many arguments")
+	}
+	db, err := getDBInfoByID(schemaDBID)
+	if err != nil {
+		return err
+	}
+	res, err := json.MarshalIndent(db, "", "    ")
+	if err != nil {
+		return err
+	}
+	c.Println(string(res))
+	return nil
+}
+
+// getDBInfoByID returns the info of the database, the status API has no lookup by database ID so all databases are scanned.
+func getDBInfoByID(id int64) (*model.DBInfo, error) {
+	dbs, err := getDBInfos()
+	if err != nil {
+		return nil, err
+	}
+	for _, db := range dbs {
+		if db.ID == id {
+			return db, nil
+		}
+	}
+	return nil, fmt.Errorf("database id %d is not found", id)
+}
+
+// getDBInfos returns the infos of all databases.
+func getDBInfos() ([]*model.DBInfo, error) {
+	if len(schemaFile) != 0 {
+		snap, err := getSchemaSnapshot()
+		if err != nil {
+			return nil, err
+		}
+		dbs := make([]*model.DBInfo, 0, len(snap.Databases))
+		for _, db := range snap.Databases {
+			dbs = append(dbs, db.DBInfo)
+		}
+		return dbs, nil
+	}
+	var dbs []*model.DBInfo
+	err := httpGetJSON(schemaRoot, &dbs)
+	return dbs, err
+}
+
+// getTableInfos returns the infos of all tables in the database.
+func getTableInfos(db string) ([]*model.TableInfo, error) {
+	if len(schemaFile) != 0 {
+		snap, err := getSchemaSnapshot()
+		if err != nil {
+			return nil, err
+		}
+		dbSnap, err := snap.findDB(db)
+		if err != nil {
+			return nil, err
+		}
+		return dbSnap.Tables, nil
+	}
+	var tables []*model.TableInfo
+	err := httpGetJSON(schemaRootPrefix+db, &tables)
+	return tables, err
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&schemaTestSuite{})
+
+type schemaTestSuite struct{}
+
+const testSchemaSnapshot = `{"databases":[{"db_info":{"id":1,"db_name":{"O":"test","L":"test"},"charset":"utf8mb4","collate":"utf8mb4_bin"},
+"tables":[{"id":45,"name":{"O":"t","L":"t"},"charset":"utf8mb4","collate":"utf8mb4_bin",
+	"cols":[{"id":1,"name":{"O":"a","L":"a"},"offset":0,"type":{"Tp":3,"Flag":4099,"Flen":11,"Decimal":0},"state":5},
+		{"id":2,"name":{"O":"b","L":"b"},"offset":1,"type":{"Tp":15,"Flag":0,"Flen":20,"Decimal":0},"state":5}],
+	"index_info":[{"id":1,"idx_name":{"O":"idx_b","L":"idx_b"},"idx_cols":[{"name":{"O":"b","L":"b"},"offset":1,"length":-1}],"state":5}],
+	"pk_is_handle":true,"comment":"","state":5},
+	{"id":46,"name":{"O":"t2","L":"t2"},"cols":[],"state":5}]}]}`
+
+const testSchemaSnapshot2 = `{"databases":[{"db_info":{"id":1,"db_name":{"O":"test","L":"test"},"charset":"utf8mb4","collate":"utf8mb4_bin"},
+"tables":[{"id":45,"name":{"O":"t","L":"t"},"charset":"utf8mb4","collate":"utf8mb4_bin",
+	"cols":[{"id":1,"name":{"O":"a","L":"a"},"offset":0,"type":{"Tp":3,"Flag":4099,"Flen":11,"Decimal":0},"state":5},
+		{"id":2,"name":{"O":"b","L":"b"},"offset":1,"type":{"Tp":15,"Flag":0,"Flen":30,"Decimal":0},"state":5},
+		{"id":3,"name":{"O":"c","L":"c"},"offset":2,"type":{"Tp":8,"Flag":1,"Flen":20,"Decimal":0},"default":"0","state":5}],
+	"pk_is_handle":true,"comment":"new","state":5}]},
+	{"db_info":{"id":2,"db_name":{"O":"test2","L":"test2"}},"tables":[]}]}`
+
+func writeTestSnapshot(c *C, name, content string) string {
+	path := filepath.Join(c.MkDir(), name)
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+	return path
+}
+
+func (s *schemaTestSuite) TestSchemaDiff(c *C) {
+	from := writeTestSnapshot(c, "from.json", testSchemaSnapshot)
+	to := writeTestSnapshot(c, "to.json", testSchemaSnapshot2)
+	cmd := initCommand()
+	args := []string{"schema", "diff", from, to}
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "--- "+from+"\n+++ "+to+"\n"+
+		"table test.t:\n"+
+		"  - column `b` varchar(20) DEFAULT NULL\n"+
+		"  + column `b` varchar(30) DEFAULT NULL\n"+
+		"  + column `c` bigint(20) NOT NULL DEFAULT '0'\n"+
+		"  - index KEY `idx_b` (`b`)\n"+
+		"  ~ option comment:  -> new\n"+
+		"- table test.t2\n"+
+		"+ database test2\n")
+
+	args = []string{"schema", "diff", from, from}
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "--- "+from+"\n+++ "+from+"\nno difference\n")
+}
+
+func (s *schemaTestSuite) TestSchemaExport(c *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp string
+		switch r.URL.EscapedPath() {
+		case "/schema":
+			resp = `[{"id":1,"db_name":{"O":"test","L":"test"}}]`
+		case "/schema/test":
+			resp = `[{"id":45,"name":{"O":"t","L":"t"},"index_info":[{"id":1,"idx_name":{"O":"idx_b","L":"idx_b"}}]}]`
+		default:
+			c.Fatalf("unexpected path %s", r.URL.EscapedPath())
+		}
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(resp))
+		c.Assert(err, IsNil)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	c.Assert(err, IsNil)
+	uArr := strings.Split(u.Host, ":")
+	path := filepath.Join(c.MkDir(), "schema.json")
+	cmd := initCommand()
+	args := []string{"schema", "export", "-o", path, "-H", uArr[0], "-P", uArr[1]}
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "exported 1 databases and 1 tables to "+path+"\n")
+	ts.Close()
+
+	// The key ranges can be shown without the TiDB server.
+	defer func() { schemaFile = "" }()
+	args = []string{"keyrange", "-d", "test", "-t", "t", "--schema-file", path}
+	_, _, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	tblInfo, err := getTableInfo("45")
+	c.Assert(err, IsNil)
+	c.Assert(tblInfo.Name.O, Equals, "t")
+	info, err := getDBTableInfo(45)
+	c.Assert(err, IsNil)
+	c.Assert(info.DBInfo.Name.O, Equals, "test")
+}
+
+func (s *schemaTestSuite) TestShowCreate(c *C) {
+	path := writeTestSnapshot(c, "schema.json", testSchemaSnapshot)
+	defer func() { schemaFile = "" }()
+	cmd := initCommand()
+	args := []string{"schema", "show-create", "test", "-n", "t", "--schema-file", path}
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "CREATE TABLE `t` (\n"+
+		"  `a` int(11) NOT NULL,\n"+
+		"  `b` varchar(20) DEFAULT NULL,\n"+
+		"  PRIMARY KEY (`a`),\n"+
+		"  KEY `idx_b` (`b`)\n"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;\n")
+}
+
+func (s *schemaTestSuite) TestSchemaSearch(c *C) {
+	path := writeTestSnapshot(c, "schema.json", testSchemaSnapshot)
+	defer func() { schemaFile = "" }()
+	cmd := initCommand()
+	args := []string{"schema", "search", "--type", "VARCHAR", "--index-id", "1", "--schema-file", path}
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "test.t (id: 45)\n"+
+		"  column `b` varchar(20) DEFAULT NULL\n"+
+		"  index KEY `idx_b` (`b`) (id: 1)\n")
+
+	args = []string{"schema", "search", "--type", "", "--index-id", "0", "--table-id", "46", "--option", "pk_is_handle=true"}
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "no table found\n")
+
+	args = []string{"schema", "search", "--option", "pk_is_handle=true", "--table-id", "0"}
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "test.t (id: 45)\n  option pk_is_handle: true\n")
+}
+
+func (s *schemaTestSuite) TestSchemaList(c *C) {
+	path := writeTestSnapshot(c, "schema.json", testSchemaSnapshot)
+	defer func() { schemaFile = "" }()
+	cmd := initCommand()
+	args := []string{"schema", "ls", "--schema-file", path}
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, ""+
+		"DB    TABLE  TABLE_ID  PARTITION_IDS  INDEX_IDS  ROW_FORMAT   UPDATE_TIME\n"+
+		"test  t      45        -              1          int handle   -\n"+
+		"test  t2     46        -              -          _tidb_rowid  -\n")
+
+	db, err := getDBInfoByID(1)
+	c.Assert(err, IsNil)
+	c.Assert(db.Name.O, Equals, "test")
+	_, err = getDBInfoByID(2)
+	c.Assert(err, ErrorMatches, "database id 2 is not found")
+}
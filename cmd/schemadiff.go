@@ -0,0 +1,242 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/pingcap/parser/model"
+	"github.com/spf13/cobra"
+)
+
+var schemaDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the schema of two sources",
+	Long: `Compare the schema of two sources, e.g.
+* tidb-ctl schema diff [source1] [source2]
+* tidb-ctl schema diff [source]
+Compare the source with the server of --host and --port.
+A source is a status address like 127.0.0.1:10080 or a schema snapshot file.`,
+	RunE: diffSchema,
+}
+
+func init() {
+	schemaRootCmd.AddCommand(schemaDiffCmd)
+}
+
+func diffSchema(c *cobra.Command, args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return fmt.Errorf("expect one or two sources")
+	}
+	if len(args) == 1 {
+		args = append(args, net.JoinHostPort(host.String(), strconv.Itoa(int(port))))
+	}
+	from, err := loadSchemaSource(args[0])
+	if err != nil {
+		return err
+	}
+	to, err := loadSchemaSource(args[1])
+	if err != nil {
+		return err
+	}
+	c.Printf("--- %s\n+++ %s\n", args[0], args[1])
+	diffs := diffSchemaSnapshots(from, to)
+	if len(diffs) == 0 {
+		c.Println("no difference")
+		return nil
+	}
+	for _, d := range diffs {
+		c.Println(d)
+	}
+	return nil
+}
+
+// diffSchemaSnapshots returns the differences between two schema snapshots, the databases and tables are matched by name.
+func diffSchemaSnapshots(from, to *schemaSnapshot) []string {
+	fromDBs := make(map[string]*dbSnapshot)
+	toDBs := make(map[string]*dbSnapshot)
+	dbNames := make(map[string]struct{})
+	for _, db := range from.Databases {
+		fromDBs[db.DBInfo.Name.L] = db
+		dbNames[db.DBInfo.Name.L] = struct{}{}
+	}
+	for _, db := range to.Databases {
+		toDBs[db.DBInfo.Name.L] = db
+		dbNames[db.DBInfo.Name.L] = struct{}{}
+	}
+	var diffs []string
+	for _, name := range sortedNames(dbNames) {
+		fromDB, toDB := fromDBs[name], toDBs[name]
+		if toDB == nil {
+			diffs = append(diffs, "- database "+fromDB.DBInfo.Name.O)
+			continue
+		}
+		if fromDB == nil {
+			diffs = append(diffs, "+ database "+toDB.DBInfo.Name.O)
+			continue
+		}
+		dbName := toDB.DBInfo.Name.O
+		diffs = append(diffs, diffValues("database "+dbName+" charset", fromDB.DBInfo.Charset, toDB.DBInfo.Charset)...)
+		diffs = append(diffs, diffValues("database "+dbName+" collate", fromDB.DBInfo.Collate, toDB.DBInfo.Collate)...)
+
+		fromTables := make(map[string]*model.TableInfo)
+		toTables := make(map[string]*model.TableInfo)
+		tblNames := make(map[string]struct{})
+		for _, tbl := range fromDB.Tables {
+			fromTables[tbl.Name.L] = tbl
+			tblNames[tbl.Name.L] = struct{}{}
+		}
+		for _, tbl := range toDB.Tables {
+			toTables[tbl.Name.L] = tbl
+			tblNames[tbl.Name.L] = struct{}{}
+		}
+		for _, tblName := range sortedNames(tblNames) {
+			fromTbl, toTbl := fromTables[tblName], toTables[tblName]
+			if toTbl == nil {
+				diffs = append(diffs, "- table "+dbName+"."+fromTbl.Name.O)
+				continue
+			}
+			if fromTbl == nil {
+				diffs = append(diffs, "+ table "+dbName+"."+toTbl.Name.O)
+				continue
+			}
+			if tblDiffs := diffTableInfo(fromTbl, toTbl); len(tblDiffs) > 0 {
+				diffs = append(diffs, "table "+dbName+"."+toTbl.Name.O+":")
+				for _, d := range tblDiffs {
+					diffs = append(diffs, "  "+d)
+				}
+			}
+		}
+	}
+	return diffs
+}
+
+// diffTableInfo returns the differences of columns, indexes, partitions and table options.
+func diffTableInfo(from, to *model.TableInfo) []string {
+	var diffs []string
+	fromCols := make(map[string]string)
+	toCols := make(map[string]string)
+	for _, col := range from.Columns {
+		fromCols[col.Name.L] = columnDefinition(from, col)
+	}
+	for _, col := range to.Columns {
+		toCols[col.Name.L] = columnDefinition(to, col)
+	}
+	diffs = append(diffs, diffDefinitions("column", fromCols, toCols)...)
+
+	fromIdxs := make(map[string]string)
+	toIdxs := make(map[string]string)
+	for _, idx := range from.Indices {
+		fromIdxs[idx.Name.L] = indexDefinition(from, idx)
+	}
+	for _, idx := range to.Indices {
+		toIdxs[idx.Name.L] = indexDefinition(to, idx)
+	}
+	diffs = append(diffs, diffDefinitions("index", fromIdxs, toIdxs)...)
+
+	fromPartType, fromParts := partitionDefinitions(from)
+	toPartType, toParts := partitionDefinitions(to)
+	diffs = append(diffs, diffValues("partition by", fromPartType, toPartType)...)
+	diffs = append(diffs, diffDefinitions("partition", fromParts, toParts)...)
+
+	fromOpts, toOpts := tableOptions(from), tableOptions(to)
+	for _, name := range unionKeys(fromOpts, toOpts) {
+		diffs = append(diffs, diffValues("option "+name, fromOpts[name], toOpts[name])...)
+	}
+	return diffs
+}
+
+func diffDefinitions(kind string, from, to map[string]string) []string {
+	var diffs []string
+	for _, name := range unionKeys(from, to) {
+		fromDef, inFrom := from[name]
+		toDef, inTo := to[name]
+		switch {
+		case !inTo:
+			diffs = append(diffs, "- "+kind+" "+fromDef)
+		case !inFrom:
+			diffs = append(diffs, "+ "+kind+" "+toDef)
+		case fromDef != toDef:
+			diffs = append(diffs, "- "+kind+" "+fromDef, "+ "+kind+" "+toDef)
+		}
+	}
+	return diffs
+}
+
+func diffValues(name, from, to string) []string {
+	if from == to {
+		return nil
+	}
+	return []string{fmt.Sprintf("~ %s: %s -> %s", name, from, to)}
+}
+
+// unionKeys returns the sorted keys in either of the maps.
+func unionKeys(a, b map[string]string) []string {
+	names := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		names[k] = struct{}{}
+	}
+	for k := range b {
+		names[k] = struct{}{}
+	}
+	return sortedNames(names)
+}
+
+func sortedNames(names map[string]struct{}) []string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// partitionDefinitions returns the partition type and the partition definitions by partition name.
+func partitionDefinitions(tbl *model.TableInfo) (string, map[string]string) {
+	defs := make(map[string]string)
+	pi := tbl.GetPartitionInfo()
+	if pi == nil {
+		return "", defs
+	}
+	for _, def := range pi.Definitions {
+		defs[def.Name.L] = partitionDefinition(def)
+	}
+	return partitionBy(pi), defs
+}
+
+// tableOptions returns the options of a table by option name.
+func tableOptions(tbl *model.TableInfo) map[string]string {
+	opts := map[string]string{
+		"charset":           tbl.Charset,
+		"collate":           tbl.Collate,
+		"comment":           tbl.Comment,
+		"compression":       tbl.Compression,
+		"auto_id_cache":     strconv.FormatInt(tbl.AutoIdCache, 10),
+		"shard_row_id_bits": strconv.FormatUint(tbl.ShardRowIDBits, 10),
+		"pre_split_regions": strconv.FormatUint(tbl.PreSplitRegions, 10),
+		"auto_random_bits":  strconv.FormatUint(tbl.AutoRandomBits, 10),
+		"pk_is_handle":      strconv.FormatBool(tbl.PKIsHandle),
+		"tiflash_replica":   "0",
+	}
+	if tbl.TiFlashReplica != nil {
+		opts["tiflash_replica"] = strconv.FormatUint(tbl.TiFlashReplica.Count, 10)
+	}
+	if tbl.View != nil {
+		opts["view"] = tbl.View.SelectStmt
+	}
+	return opts
+}
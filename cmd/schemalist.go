@@ -0,0 +1,115 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/parser/model"
+	"github.com/spf13/cobra"
+)
+
+var schemaListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List tables with their IDs",
+	Long: `List the tables with their IDs instead of the full schema info, e.g.
+* tidb-ctl schema ls
+List the tables of all databases.
+* tidb-ctl schema ls [database name]
+List the tables of the database.`,
+	RunE: listSchema,
+}
+
+func init() {
+	schemaRootCmd.AddCommand(schemaListCmd)
+}
+
+func listSchema(c *cobra.Command, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+	var dbNames []string
+	if len(args) == 1 {
+		dbNames = append(dbNames, args[0])
+	} else {
+		dbs, err := getDBInfos()
+		if err != nil {
+			return err
+		}
+		for _, db := range dbs {
+			dbNames = append(dbNames, db.Name.O)
+		}
+	}
+	var rows [][]string
+	for _, dbName := range dbNames {
+		tables, err := getTableInfos(dbName)
+		if err != nil {
+			return err
+		}
+		for _, tbl := range tables {
+			rows = append(rows, []string{dbName, tbl.Name.O, strconv.FormatInt(tbl.ID, 10),
+				partitionIDs(tbl), indexIDs(tbl), rowFormat(tbl), updateTime(tbl)})
+		}
+	}
+	printTable(c, []string{"DB", "TABLE", "TABLE_ID", "PARTITION_IDS", "INDEX_IDS", "ROW_FORMAT", "UPDATE_TIME"}, rows)
+	return nil
+}
+
+func partitionIDs(tbl *model.TableInfo) string {
+	pi := tbl.GetPartitionInfo()
+	if pi == nil {
+		return "-"
+	}
+	ids := make([]string, 0, len(pi.Definitions))
+	for _, def := range pi.Definitions {
+		ids = append(ids, strconv.FormatInt(def.ID, 10))
+	}
+	return strings.Join(ids, ",")
+}
+
+func indexIDs(tbl *model.TableInfo) string {
+	if len(tbl.Indices) == 0 {
+		return "-"
+	}
+	ids := make([]string, 0, len(tbl.Indices))
+	for _, idx := range tbl.Indices {
+		ids = append(ids, strconv.FormatInt(idx.ID, 10))
+	}
+	return strings.Join(ids, ",")
+}
+
+// rowFormat returns how the rows of the table are keyed.
+func rowFormat(tbl *model.TableInfo) string {
+	switch {
+	case tbl.IsView():
+		return "view"
+	case tbl.IsSequence():
+		return "sequence"
+	case tbl.IsCommonHandle:
+		return "clustered"
+	case tbl.PKIsHandle:
+		return "int handle"
+	default:
+		return "_tidb_rowid"
+	}
+}
+
+func updateTime(tbl *model.TableInfo) string {
+	if tbl.UpdateTS == 0 {
+		return "-"
+	}
+	return tsToTime(tbl.UpdateTS).Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,219 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/spf13/cobra"
+)
+
+// search command flags
+var (
+	searchColumn      string
+	searchColumnType  string
+	searchIndex       string
+	searchIndexID     int64
+	searchTableID     int64
+	searchPartitionID int64
+	searchOptions     []string
+)
+
+var schemaSearchCmd = &cobra.Command{
+	Use:   "search",
+	Short: "Search tables by column, index or ID",
+	Long: `Search the tables of all databases, e.g.
+* tidb-ctl schema search --column user_id
+Find the tables with a column matching the regular expression.
+* tidb-ctl schema search --table-id 1234 --index-id 7
+Find the index with ID 7 of table 1234.
+* tidb-ctl schema search --option shard_row_id_bits=4
+Find the tables with the table option, the options are those compared by 'schema diff'.
+All the filters must be matched, the patterns are case-insensitive.`,
+	RunE: searchSchema,
+}
+
+func init() {
+	schemaRootCmd.AddCommand(schemaSearchCmd)
+	schemaSearchCmd.Flags().StringVar(&searchColumn, "column", "", "the pattern of column names")
+	schemaSearchCmd.Flags().StringVar(&searchColumnType, "type", "", "the pattern of column types, e.g. 'varchar|text'")
+	schemaSearchCmd.Flags().StringVar(&searchIndex, "index", "", "the pattern of index names")
+	schemaSearchCmd.Flags().Int64Var(&searchIndexID, "index-id", 0, "the index ID")
+	schemaSearchCmd.Flags().Int64Var(&searchTableID, "table-id", 0, "the table ID")
+	schemaSearchCmd.Flags().Int64Var(&searchPartitionID, "partition-id", 0, "the partition ID")
+	schemaSearchCmd.Flags().StringSliceVar(&searchOptions, "option", nil, "the table options in the form of name=value")
+}
+
+// schemaFilter matches a table and returns the matched definitions.
+type schemaFilter struct {
+	column      *regexp.Regexp
+	columnType  *regexp.Regexp
+	index       *regexp.Regexp
+	indexID     int64
+	tableID     int64
+	partitionID int64
+	options     map[string]string
+}
+
+func searchSchema(c *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("too many arguments")
+	}
+	filter, err := newSchemaFilter()
+	if err != nil {
+		return err
+	}
+	dbs, err := getDBInfos()
+	if err != nil {
+		return err
+	}
+	found := 0
+	for _, db := range dbs {
+		tables, err := getTableInfos(db.Name.O)
+		if err != nil {
+			return err
+		}
+		for _, tbl := range tables {
+			matched, ok := filter.match(tbl)
+			if !ok {
+				continue
+			}
+			found++
+			c.Printf("%s.%s (id: %d)\n", db.Name.O, tbl.Name.O, tbl.ID)
+			for _, m := range matched {
+				c.Printf("  %s\n", m)
+			}
+		}
+	}
+	if found == 0 {
+		c.Println("no table found")
+	}
+	return nil
+}
+
+func newSchemaFilter() (*schemaFilter, error) {
+	f := &schemaFilter{
+		indexID:     searchIndexID,
+		tableID:     searchTableID,
+		partitionID: searchPartitionID,
+	}
+	var err error
+	if f.column, err = compilePattern("column", searchColumn); err != nil {
+		return nil, err
+	}
+	if f.columnType, err = compilePattern("type", searchColumnType); err != nil {
+		return nil, err
+	}
+	if f.index, err = compilePattern("index", searchIndex); err != nil {
+		return nil, err
+	}
+	if len(searchOptions) > 0 {
+		f.options = make(map[string]string, len(searchOptions))
+		for _, opt := range searchOptions {
+			kv := strings.SplitN(opt, "=", 2)
+			if len(kv) != 2 {
+				return nil, errors.Errorf("invalid option %s, expect name=value", opt)
+			}
+			f.options[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+		}
+	}
+	if f.column == nil && f.columnType == nil && f.index == nil && f.indexID == 0 &&
+		f.tableID == 0 && f.partitionID == 0 && len(f.options) == 0 {
+		return nil, errors.New("at least one filter should be set")
+	}
+	return f, nil
+}
+
+func compilePattern(name, pattern string) (*regexp.Regexp, error) {
+	if len(pattern) == 0 {
+		return nil, nil
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, errors.Errorf("invalid %s pattern %s: %v", name, pattern, err)
+	}
+	return re, nil
+}
+
+// match returns the matched definitions of the table, and whether all the filters are matched.
+func (f *schemaFilter) match(tbl *model.TableInfo) ([]string, bool) {
+	var matched []string
+	if f.tableID != 0 && tbl.ID != f.tableID {
+		return nil, false
+	}
+	if f.partitionID != 0 {
+		pi := tbl.GetPartitionInfo()
+		if pi == nil {
+			return nil, false
+		}
+		found := false
+		for _, def := range pi.Definitions {
+			if def.ID == f.partitionID {
+				matched = append(matched, fmt.Sprintf("partition %s (id: %d)", partitionDefinition(def), def.ID))
+				found = true
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	if f.column != nil || f.columnType != nil {
+		found := false
+		for _, col := range tbl.Columns {
+			if f.column != nil && !f.column.MatchString(col.Name.O) {
+				continue
+			}
+			if f.columnType != nil && !f.columnType.MatchString(col.GetTypeDesc()) {
+				continue
+			}
+			matched = append(matched, "column "+columnDefinition(tbl, col))
+			found = true
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	if f.index != nil || f.indexID != 0 {
+		found := false
+		for _, idx := range tbl.Indices {
+			if f.index != nil && !f.index.MatchString(idx.Name.O) {
+				continue
+			}
+			if f.indexID != 0 && idx.ID != f.indexID {
+				continue
+			}
+			matched = append(matched, fmt.Sprintf("index %s (id: %d)", indexDefinition(tbl, idx), idx.ID))
+			found = true
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	if len(f.options) > 0 {
+		opts := tableOptions(tbl)
+		for name, value := range f.options {
+			if !strings.EqualFold(opts[name], value) {
+				return nil, false
+			}
+		}
+		for _, name := range unionKeys(f.options, nil) {
+			matched = append(matched, fmt.Sprintf("option %s: %s", name, opts[name]))
+		}
+	}
+	return matched, true
+}
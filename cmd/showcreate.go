@@ -0,0 +1,286 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/spf13/cobra"
+)
+
+var showCreateTable string
+
+var showCreateCmd = &cobra.Command{
+	Use:   "show-create",
+	Short: "Show the CREATE TABLE statements of tables",
+	Long: `Show the CREATE TABLE statements, e.g.
+* tidb-ctl schema show-create [database name]
+Show the statements of all tables in the database.
+* tidb-ctl schema show-create [database name] --name(-n) [table name]
+Show the statement of a specified table in database.
+The table infos are read from --schema-file if it's set.`,
+	RunE: showCreate,
+}
+
+func init() {
+	schemaRootCmd.AddCommand(showCreateCmd)
+	showCreateCmd.Flags().StringVarP(&showCreateTable, "name", "n", "", "table name")
+}
+
+func showCreate(c *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expect one argument as database name")
+	}
+	var tables []*model.TableInfo
+	if len(showCreateTable) != 0 {
+		tblInfo, err := getTableInfo(args[0] + "." + showCreateTable)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, tblInfo)
+	} else {
+		var err error
+		if tables, err = getTableInfos(args[0]); err != nil {
+			return err
+		}
+	}
+	for _, tbl := range tables {
+		c.Println(showCreateStatement(tbl) + ";")
+	}
+	return nil
+}
+
+// showCreateStatement returns the CREATE statement of a table, view or sequence like SHOW CREATE TABLE.
+func showCreateStatement(tbl *model.TableInfo) string {
+	if tbl.IsView() {
+		return showCreateView(tbl)
+	}
+	if tbl.IsSequence() {
+		return showCreateSequence(tbl)
+	}
+	var buf strings.Builder
+	buf.WriteString("CREATE TABLE " + quoteName(tbl.Name.O) + " (\n")
+	var defs []string
+	for _, col := range tbl.Columns {
+		if col.State != model.StatePublic || col.Hidden {
+			continue
+		}
+		defs = append(defs, columnDefinition(tbl, col))
+	}
+	if tbl.PKIsHandle {
+		for _, col := range tbl.Columns {
+			if mysql.HasPriKeyFlag(col.Flag) {
+				defs = append(defs, "PRIMARY KEY ("+quoteName(col.Name.O)+")")
+				break
+			}
+		}
+	}
+	for _, idx := range tbl.Indices {
+		if idx.State != model.StatePublic {
+			continue
+		}
+		defs = append(defs, indexDefinition(tbl, idx))
+	}
+	buf.WriteString("  " + strings.Join(defs, ",\n  ") + "\n)")
+
+	buf.WriteString(" ENGINE=InnoDB")
+	if len(tbl.Charset) != 0 {
+		buf.WriteString(" DEFAULT CHARSET=" + tbl.Charset)
+	}
+	if len(tbl.Collate) != 0 {
+		buf.WriteString(" COLLATE=" + tbl.Collate)
+	}
+	if tbl.AutoIncID > 0 {
+		buf.WriteString(" AUTO_INCREMENT=" + strconv.FormatInt(tbl.AutoIncID, 10))
+	}
+	if tbl.AutoIdCache != 0 {
+		buf.WriteString(" /*T![auto_id_cache] AUTO_ID_CACHE=" + strconv.FormatInt(tbl.AutoIdCache, 10) + " */")
+	}
+	if tbl.ShardRowIDBits > 0 {
+		buf.WriteString(" /*!90000 SHARD_ROW_ID_BITS=" + strconv.FormatUint(tbl.ShardRowIDBits, 10))
+		if tbl.PreSplitRegions > 0 {
+			buf.WriteString(" PRE_SPLIT_REGIONS=" + strconv.FormatUint(tbl.PreSplitRegions, 10))
+		}
+		buf.WriteString(" */")
+	}
+	if len(tbl.Compression) != 0 {
+		buf.WriteString(" COMPRESSION=" + quoteString(tbl.Compression))
+	}
+	if len(tbl.Comment) != 0 {
+		buf.WriteString(" COMMENT=" + quoteString(tbl.Comment))
+	}
+	if pi := tbl.GetPartitionInfo(); pi != nil {
+		buf.WriteString("\nPARTITION BY " + partitionBy(pi))
+		if pi.Type == model.PartitionTypeHash {
+			buf.WriteString("\nPARTITIONS " + strconv.Itoa(len(pi.Definitions)))
+		} else {
+			defs := make([]string, 0, len(pi.Definitions))
+			for _, def := range pi.Definitions {
+				defs = append(defs, partitionDefinition(def))
+			}
+			buf.WriteString("\n(" + strings.Join(defs, ",\n ") + ")")
+		}
+	}
+	return buf.String()
+}
+
+func showCreateView(tbl *model.TableInfo) string {
+	v := tbl.View
+	var buf strings.Builder
+	buf.WriteString("CREATE ALGORITHM=" + v.Algorithm.String())
+	if v.Definer != nil {
+		buf.WriteString(" DEFINER=" + quoteName(v.Definer.Username) + "@" + quoteName(v.Definer.Hostname))
+	}
+	buf.WriteString(" SQL SECURITY " + v.Security.String() + " VIEW " + quoteName(tbl.Name.O))
+	if len(v.Cols) > 0 {
+		cols := make([]string, 0, len(v.Cols))
+		for _, col := range v.Cols {
+			cols = append(cols, quoteName(col.O))
+		}
+		buf.WriteString(" (" + strings.Join(cols, ", ") + ")")
+	}
+	buf.WriteString(" AS " + v.SelectStmt)
+	return buf.String()
+}
+
+func showCreateSequence(tbl *model.TableInfo) string {
+	s := tbl.Sequence
+	var buf strings.Builder
+	buf.WriteString("CREATE SEQUENCE " + quoteName(tbl.Name.O))
+	buf.WriteString(fmt.Sprintf(" start with %d minvalue %d maxvalue %d increment by %d", s.Start, s.MinValue, s.MaxValue, s.Increment))
+	if s.Cache {
+		buf.WriteString(" cache " + strconv.FormatInt(s.CacheValue, 10))
+	} else {
+		buf.WriteString(" nocache")
+	}
+	if s.Cycle {
+		buf.WriteString(" cycle")
+	} else {
+		buf.WriteString(" nocycle")
+	}
+	if len(s.Comment) != 0 {
+		buf.WriteString(" COMMENT=" + quoteString(s.Comment))
+	}
+	return buf.String()
+}
+
+// columnDefinition returns the column definition in the form of CREATE TABLE.
+func columnDefinition(tbl *model.TableInfo, col *model.ColumnInfo) string {
+	var buf strings.Builder
+	buf.WriteString(quoteName(col.Name.O) + " " + col.GetTypeDesc())
+	if len(col.Collate) != 0 && col.Collate != tbl.Collate && col.Charset != "binary" {
+		buf.WriteString(" COLLATE " + col.Collate)
+	}
+	if col.IsGenerated() {
+		buf.WriteString(" GENERATED ALWAYS AS (" + col.GeneratedExprString + ")")
+		if col.GeneratedStored {
+			buf.WriteString(" STORED")
+		} else {
+			buf.WriteString(" VIRTUAL")
+		}
+	}
+	if mysql.HasNotNullFlag(col.Flag) {
+		buf.WriteString(" NOT NULL")
+	}
+	if mysql.HasAutoIncrementFlag(col.Flag) {
+		buf.WriteString(" AUTO_INCREMENT")
+	}
+	if tbl.AutoRandomBits > 0 && tbl.PKIsHandle && mysql.HasPriKeyFlag(col.Flag) {
+		buf.WriteString(" /*T![auto_rand] AUTO_RANDOM(" + strconv.FormatUint(tbl.AutoRandomBits, 10) + ") */")
+	}
+	if !mysql.HasNoDefaultValueFlag(col.Flag) && !mysql.HasAutoIncrementFlag(col.Flag) && !col.IsGenerated() {
+		switch def := col.GetDefaultValue(); {
+		case def == nil:
+			if !mysql.HasNotNullFlag(col.Flag) {
+				buf.WriteString(" DEFAULT NULL")
+			}
+		case strings.HasPrefix(strings.ToUpper(fmt.Sprint(def)), "CURRENT_TIMESTAMP"):
+			buf.WriteString(" DEFAULT " + strings.ToUpper(fmt.Sprint(def)))
+		default:
+			buf.WriteString(" DEFAULT " + quoteString(fmt.Sprint(def)))
+		}
+	}
+	if mysql.HasOnUpdateNowFlag(col.Flag) {
+		buf.WriteString(" ON UPDATE CURRENT_TIMESTAMP")
+	}
+	if len(col.Comment) != 0 {
+		buf.WriteString(" COMMENT " + quoteString(col.Comment))
+	}
+	return buf.String()
+}
+
+// indexDefinition returns the index definition in the form of CREATE TABLE.
+func indexDefinition(tbl *model.TableInfo, idx *model.IndexInfo) string {
+	var buf strings.Builder
+	switch {
+	case idx.Primary:
+		buf.WriteString("PRIMARY KEY ")
+	case idx.Unique:
+		buf.WriteString("UNIQUE KEY " + quoteName(idx.Name.O) + " ")
+	default:
+		buf.WriteString("KEY " + quoteName(idx.Name.O) + " ")
+	}
+	cols := make([]string, 0, len(idx.Columns))
+	for _, col := range idx.Columns {
+		name := quoteName(col.Name.O)
+		if col.Length != -1 && col.Length != 0 {
+			name += "(" + strconv.Itoa(col.Length) + ")"
+		}
+		cols = append(cols, name)
+	}
+	buf.WriteString("(" + strings.Join(cols, ",") + ")")
+	if idx.Invisible {
+		buf.WriteString(" /*!80000 INVISIBLE */")
+	}
+	if len(idx.Comment) != 0 {
+		buf.WriteString(" COMMENT " + quoteString(idx.Comment))
+	}
+	return buf.String()
+}
+
+// partitionBy returns the partition type and expression like RANGE (`a`).
+func partitionBy(pi *model.PartitionInfo) string {
+	if len(pi.Columns) > 0 {
+		cols := make([]string, 0, len(pi.Columns))
+		for _, col := range pi.Columns {
+			cols = append(cols, quoteName(col.O))
+		}
+		return pi.Type.String() + " COLUMNS(" + strings.Join(cols, ",") + ")"
+	}
+	return pi.Type.String() + " (" + pi.Expr + ")"
+}
+
+// partitionDefinition returns the partition definition in the form of CREATE TABLE.
+func partitionDefinition(def model.PartitionDefinition) string {
+	d := "PARTITION " + quoteName(def.Name.O)
+	if len(def.LessThan) > 0 {
+		d += " VALUES LESS THAN (" + strings.Join(def.LessThan, ",") + ")"
+	}
+	if len(def.Comment) != 0 {
+		d += " COMMENT " + quoteString(def.Comment)
+	}
+	return d
+}
+
+func quoteName(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+func quoteString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
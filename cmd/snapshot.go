@@ -0,0 +1,185 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportOutputPath string
+
+	cachedSnapshot     *schemaSnapshot
+	cachedSnapshotPath string
+)
+
+// schemaSnapshot is the schema of all databases saved by `tidb-ctl schema export`.
+type schemaSnapshot struct {
+	Source     string        `json:"source"`
+	ExportTime time.Time     `json:"export_time"`
+	Databases  []*dbSnapshot `json:"databases"`
+}
+
+// dbSnapshot is the schema of a database.
+type dbSnapshot struct {
+	DBInfo *model.DBInfo      `json:"db_info"`
+	Tables []*model.TableInfo `json:"tables"`
+}
+
+// fetchSchemaSnapshot gets the schema of all databases from the TiDB server with the status address addr.
+func fetchSchemaSnapshot(addr string) (*schemaSnapshot, error) {
+	var dbs []*model.DBInfo
+	if err := httpGetJSONFrom(addr, schemaRoot, &dbs); err != nil {
+		return nil, err
+	}
+	snap := &schemaSnapshot{Source: addr, ExportTime: time.Now()}
+	for _, db := range dbs {
+		var tables []*model.TableInfo
+		if err := httpGetJSONFrom(addr, schemaRootPrefix+db.Name.O, &tables); err != nil {
+			return nil, err
+		}
+		snap.Databases = append(snap.Databases, &dbSnapshot{DBInfo: db, Tables: tables})
+	}
+	return snap, nil
+}
+
+// loadSchemaSnapshot reads the schema snapshot from a file.
+func loadSchemaSnapshot(path string) (*schemaSnapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snap := &schemaSnapshot{}
+	if err = json.Unmarshal(data, snap); err != nil {
+		return nil, errors.Errorf("invalid schema snapshot %s: %v", path, err)
+	}
+	return snap, nil
+}
+
+// loadSchemaSource loads the schema snapshot from a file, or from a TiDB server if source is a status address.
+func loadSchemaSource(source string) (*schemaSnapshot, error) {
+	if fi, err := os.Stat(source); err == nil && !fi.IsDir() {
+		return loadSchemaSnapshot(source)
+	}
+	if _, _, err := net.SplitHostPort(source); err != nil {
+		return nil, errors.Errorf("%s is neither a schema snapshot file nor a status address", source)
+	}
+	return fetchSchemaSnapshot(source)
+}
+
+// getSchemaSnapshot returns the snapshot of --schema-file.
+func getSchemaSnapshot() (*schemaSnapshot, error) {
+	if cachedSnapshot != nil && cachedSnapshotPath == schemaFile {
+		return cachedSnapshot, nil
+	}
+	snap, err := loadSchemaSnapshot(schemaFile)
+	if err != nil {
+		return nil, err
+	}
+	cachedSnapshot, cachedSnapshotPath = snap, schemaFile
+	return snap, nil
+}
+
+// findDB returns the schema of the database.
+func (s *schemaSnapshot) findDB(dbName string) (*dbSnapshot, error) {
+	for _, db := range s.Databases {
+		if db.DBInfo.Name.L == strings.ToLower(dbName) {
+			return db, nil
+		}
+	}
+	return nil, errors.Errorf("database %s is not found in the schema snapshot", dbName)
+}
+
+// findTableByName returns the info of the table in the database.
+func (s *schemaSnapshot) findTableByName(dbName, tableName string) (*model.TableInfo, error) {
+	db, err := s.findDB(dbName)
+	if err != nil {
+		return nil, err
+	}
+	for _, tbl := range db.Tables {
+		if tbl.Name.L == strings.ToLower(tableName) {
+			return tbl, nil
+		}
+	}
+	return nil, errors.Errorf("table %s.%s is not found in the schema snapshot", dbName, tableName)
+}
+
+// findTableByID returns the infos of the database and table by physical table ID, which may be a partition ID.
+func (s *schemaSnapshot) findTableByID(id int64) (*model.DBInfo, *model.TableInfo, error) {
+	for _, db := range s.Databases {
+		for _, tbl := range db.Tables {
+			if tbl.ID == id {
+				return db.DBInfo, tbl, nil
+			}
+			if pi := tbl.GetPartitionInfo(); pi != nil {
+				for _, def := range pi.Definitions {
+					if def.ID == id {
+						return db.DBInfo, tbl, nil
+					}
+				}
+			}
+		}
+	}
+	return nil, nil, errors.Errorf("table id %d is not found in the schema snapshot", id)
+}
+
+var schemaExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Save the schema of all databases to a file",
+	Long:  "tidb-ctl schema export [-o /path/to/schema.json], the file can be used by --schema-file when the TiDB server is not accessible",
+	RunE:  exportSchema,
+}
+
+func init() {
+	schemaRootCmd.AddCommand(schemaExportCmd)
+	schemaExportCmd.Flags().StringVarP(&exportOutputPath, "output", "o", "", "the schema snapshot output path")
+}
+
+func exportSchema(c *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("too many arguments")
+	}
+	snap, err := fetchSchemaSnapshot(net.JoinHostPort(host.String(), strconv.Itoa(int(port))))
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snap, "", "    ")
+	if err != nil {
+		return err
+	}
+	path := exportOutputPath
+	if len(path) == 0 {
+		path = fmt.Sprintf("tidb-ctl-schema.%s.json", snap.ExportTime.Format("2006-01-02.15.04.05"))
+	}
+	if err = ioutil.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	tables := 0
+	for _, db := range snap.Databases {
+		tables += len(db.Tables)
+	}
+	c.Printf("exported %d databases and %d tables to %s\n", len(snap.Databases), tables, path)
+	return nil
+}
@@ -0,0 +1,284 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/spf13/cobra"
+)
+
+const (
+	pdSplitRegionsPath   = "/pd/api/v1/regions/split"
+	pdScatterRegionsPath = "/pd/api/v1/regions/scatter"
+
+	// The flags of the memcomparable format in util/codec.
+	codecBytesFlag = 1
+	codecIntFlag   = 3
+	codecUintFlag  = 4
+
+	splitRetryLimit = 5
+)
+
+// split command flags
+var (
+	splitIndex       string
+	splitLower       int64
+	splitUpper       int64
+	splitRegionCount int
+	splitBy          []string
+	splitScatter     bool
+	splitDryRun      bool
+)
+
+var splitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "split the regions of table",
+	Long: `Split the row range or an index range of the table by PD, e.g.
+* tidb-ctl table split -d [database name] -t [table name] --lower [handle] --upper [handle] --regions [N]
+Split the rows between the handles evenly into N regions.
+* tidb-ctl table split -d [database name] -t [table name] --by [handle1,handle2...]
+Split the rows at the handles.
+* tidb-ctl table split -d [database name] -t [table name] --index [index name] --by [value1,value2...]
+Split the index at the values of its first column, --lower, --upper and --regions work for integer columns,
+the string columns should use binary collations.`,
+	RunE: splitTable,
+}
+
+func init() {
+	tableRootCmd.AddCommand(splitCmd)
+	splitCmd.Flags().StringVar(&splitIndex, "index", "", "split the index instead of the rows")
+	splitCmd.Flags().Int64Var(&splitLower, "lower", 0, "the lower bound of the range to split evenly")
+	splitCmd.Flags().Int64Var(&splitUpper, "upper", 0, "the upper bound of the range to split evenly")
+	splitCmd.Flags().IntVar(&splitRegionCount, "regions", 0, "the number of regions to split the range into")
+	splitCmd.Flags().StringSliceVar(&splitBy, "by", nil, "the values to split at")
+	splitCmd.Flags().BoolVar(&splitScatter, "scatter", false, "scatter the new regions after splitting")
+	splitCmd.Flags().BoolVar(&splitDryRun, "dry-run", false, "only print the split keys")
+}
+
+func splitTable(c *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("too many arguments")
+	}
+	tblInfo, err := getTableInfo(tableDB + "." + tableTable)
+	if err != nil {
+		return err
+	}
+	keys, err := buildSplitKeys(tblInfo)
+	if err != nil {
+		return err
+	}
+	c.Println("split keys:")
+	for _, key := range keys {
+		c.Printf("  %s (%s)\n", strings.ToUpper(hex.EncodeToString(key)), describeKey(key))
+	}
+	if splitDryRun {
+		return nil
+	}
+
+	regionIDs, err := splitRegions(keys)
+	if err != nil {
+		return err
+	}
+	c.Printf("split into new regions: %v\n", regionIDs)
+	if splitScatter && len(regionIDs) > 0 {
+		if err = scatterRegions(regionIDs); err != nil {
+			return err
+		}
+		c.Println("scatter started")
+	}
+	return nil
+}
+
+// buildSplitKeys returns the raw keys to split at.
+func buildSplitKeys(tblInfo *model.TableInfo) ([][]byte, error) {
+	even := splitRegionCount > 0
+	if even == (len(splitBy) > 0) {
+		return nil, errors.New("one of --regions and --by should be set")
+	}
+	if tblInfo.GetPartitionInfo() != nil {
+		return nil, errors.New("partitioned table is not supported")
+	}
+	prefix := encodeInt([]byte("t"), tblInfo.ID)
+	// The handles are encoded as signed integers without a flag.
+	encodeValue := func(value string) ([]byte, error) {
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid handle %s: %v", value, err)
+		}
+		return encodeInt(append([]byte{}, prefix...), v), nil
+	}
+	if len(splitIndex) == 0 {
+		prefix = append(prefix, '_', 'r')
+	} else {
+		var idxInfo *model.IndexInfo
+		for _, idx := range tblInfo.Indices {
+			if idx.Name.L == strings.ToLower(splitIndex) {
+				idxInfo = idx
+				break
+			}
+		}
+		if idxInfo == nil {
+			return nil, errors.Errorf("index %s is not found in table %s", splitIndex, tblInfo.Name.O)
+		}
+		prefix = append(prefix, '_', 'i')
+		prefix = encodeInt(prefix, idxInfo.ID)
+		if len(idxInfo.Columns) == 0 || idxInfo.Columns[0].Offset >= len(tblInfo.Columns) {
+			return nil, errors.Errorf("the first column of index %s is not found in table %s", idxInfo.Name.O, tblInfo.Name.O)
+		}
+		col := tblInfo.Columns[idxInfo.Columns[0].Offset]
+		encodeIndexValue, err := newIndexValueEncoder(&col.FieldType)
+		if err != nil {
+			return nil, errors.Errorf("cannot split index %s by column %s: %v", idxInfo.Name.O, col.Name.O, err)
+		}
+		if even && !isIntegerType(col.FieldType.Tp) {
+			return nil, errors.Errorf("--regions only works for integer columns, column %s is %s", col.Name.O, col.FieldType.String())
+		}
+		encodeValue = func(value string) ([]byte, error) {
+			return encodeIndexValue(append([]byte{}, prefix...), value)
+		}
+	}
+
+	values := splitBy
+	if even {
+		if splitUpper <= splitLower {
+			return nil, errors.Errorf("upper bound %d should be greater than lower bound %d", splitUpper, splitLower)
+		}
+		step := (uint64(splitUpper) - uint64(splitLower)) / uint64(splitRegionCount)
+		if step == 0 {
+			return nil, errors.Errorf("the range is too small to split into %d regions", splitRegionCount)
+		}
+		values = nil
+		for i := 0; i < splitRegionCount; i++ {
+			values = append(values, strconv.FormatInt(splitLower+int64(step*uint64(i)), 10))
+		}
+	}
+	var keys [][]byte
+	for _, value := range values {
+		key, err := encodeValue(value)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// integerBits are the bits of the integer types.
+var integerBits = map[byte]uint{
+	mysql.TypeTiny:     8,
+	mysql.TypeShort:    16,
+	mysql.TypeInt24:    24,
+	mysql.TypeLong:     32,
+	mysql.TypeLonglong: 64,
+	mysql.TypeYear:     16,
+}
+
+func isIntegerType(tp byte) bool {
+	_, ok := integerBits[tp]
+	return ok
+}
+
+// newIndexValueEncoder returns the function to append the memcomparable form of a value of the column type to a key.
+func newIndexValueEncoder(ft *types.FieldType) (func(key []byte, value string) ([]byte, error), error) {
+	if bits, ok := integerBits[ft.Tp]; ok {
+		if mysql.HasUnsignedFlag(ft.Flag) {
+			return func(key []byte, value string) ([]byte, error) {
+				v, err := strconv.ParseUint(value, 10, int(bits))
+				if err != nil {
+					return nil, errors.Errorf("invalid value %s of %s: %v", value, ft.String(), err)
+				}
+				var data [8]byte
+				binary.BigEndian.PutUint64(data[:], v)
+				return append(append(key, codecUintFlag), data[:]...), nil
+			}, nil
+		}
+		return func(key []byte, value string) ([]byte, error) {
+			v, err := strconv.ParseInt(value, 10, int(bits))
+			if err != nil {
+				return nil, errors.Errorf("invalid value %s of %s: %v", value, ft.String(), err)
+			}
+			return encodeInt(append(key, codecIntFlag), v), nil
+		}, nil
+	}
+	if types.IsString(ft.Tp) {
+		// The index keys of the other collations are the sort keys of the values if the new collations are enabled.
+		if len(ft.Collate) != 0 && ft.Collate != charset.CollationBin && !strings.HasSuffix(ft.Collate, "_bin") {
+			return nil, errors.Errorf("collation %s is not supported, only the values of binary collations are stored as they are in the index", ft.Collate)
+		}
+		return func(key []byte, value string) ([]byte, error) {
+			return append(append(key, codecBytesFlag), encodeBytes([]byte(value))...), nil
+		}, nil
+	}
+	return nil, errors.Errorf("column type %s is not supported", ft.String())
+}
+
+// splitRegions asks PD to split regions at the raw keys and returns the IDs of the new regions.
+func splitRegions(keys [][]byte) ([]uint64, error) {
+	var input struct {
+		SplitKeys  []string `json:"split_keys"`
+		RetryLimit int      `json:"retry_limit"`
+	}
+	for _, key := range keys {
+		input.SplitKeys = append(input.SplitKeys, hex.EncodeToString(encodeBytes(key)))
+	}
+	input.RetryLimit = splitRetryLimit
+	res, err := pdPost(pdSplitRegionsPath, input)
+	if err != nil {
+		return nil, err
+	}
+	var output struct {
+		RegionIDs []uint64 `json:"regions-id"`
+	}
+	if err = json.Unmarshal([]byte(res), &output); err != nil {
+		return nil, err
+	}
+	return output.RegionIDs, nil
+}
+
+func scatterRegions(regionIDs []uint64) error {
+	var input struct {
+		RegionIDs  []uint64 `json:"regions_id"`
+		RetryLimit int      `json:"retry_limit"`
+	}
+	input.RegionIDs = regionIDs
+	input.RetryLimit = splitRetryLimit
+	_, err := pdPost(pdScatterRegionsPath, input)
+	return err
+}
+
+// pdPost posts the JSON form of input to PD and returns the response.
+func pdPost(path string, input interface{}) (string, error) {
+	reqData, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+	req, err := getRequest(path, http.MethodPost, "application/json", bytes.NewBuffer(reqData))
+	if err != nil {
+		return "", err
+	}
+	return dial(req)
+}
@@ -14,7 +14,11 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"sort"
 
 	"github.com/spf13/cobra"
 )
@@ -23,13 +27,56 @@ const (
 	tablePrefix  = "tables/"
 	regionSuffix = "regions"
 	usageSurffix = "disk-usage"
+
+	pdHotReadPath  = "/pd/api/v1/hotspot/regions/read"
+	pdHotWritePath = "/pd/api/v1/hotspot/regions/write"
 )
 
 var (
-	tableDB    string
-	tableTable string
+	tableDB       string
+	tableTable    string
+	regionSummary bool
 )
 
+// tableRegions is the response of the table regions API of the TiDB status port.
+type tableRegions struct {
+	TableName     string       `json:"name"`
+	TableID       int64        `json:"id"`
+	RecordRegions []regionMeta `json:"record_regions"`
+	Indices       []struct {
+		Name    string       `json:"name"`
+		ID      int64        `json:"id"`
+		Regions []regionMeta `json:"regions"`
+	} `json:"indices"`
+}
+
+type regionMeta struct {
+	ID     uint64      `json:"region_id"`
+	Leader *storePeer  `json:"leader"`
+	Peers  []storePeer `json:"peers"`
+}
+
+type storePeer struct {
+	ID      uint64 `json:"id"`
+	StoreID uint64 `json:"store_id"`
+}
+
+// storeRegionStat is the count of regions and leaders on a store.
+type storeRegionStat struct {
+	peers   int
+	leaders int
+}
+
+// hotRegionStats is the response of the hotspot API of PD.
+type hotRegionStats struct {
+	AsLeader map[uint64]*struct {
+		Stats []struct {
+			RegionID  uint64  `json:"region_id"`
+			FlowBytes float64 `json:"flow_bytes"`
+		} `json:"statistics"`
+	} `json:"as_leader"`
+}
+
 // tableCmd represents the table command
 var tableRootCmd = &cobra.Command{
 	Use:   "table",
@@ -39,6 +86,7 @@ var tableRootCmd = &cobra.Command{
 
 func init() {
 	tableRootCmd.AddCommand(regionCmd, diskUsageCmd)
+	regionCmd.Flags().BoolVarP(&regionSummary, "summary", "s", false, "summarize the regions by store, with the hot region flow from PD")
 	tableRootCmd.PersistentFlags().StringVarP(&tableDB, dbFlagName, "d", "", "database name")
 	tableRootCmd.PersistentFlags().StringVarP(&tableTable, tableFlagName, "t", "", "table name")
 	if err := tableRootCmd.MarkPersistentFlagRequired(dbFlagName); err != nil {
@@ -54,15 +102,149 @@ func init() {
 var regionCmd = &cobra.Command{
 	Use:   regionSuffix,
 	Short: "region info of table",
-	Long:  "tidb-ctl table region --database(-d) [database name] --table(-t) [table name]",
+	Long:  "tidb-ctl table regions --database(-d) [database name] --table(-t) [table name] [--summary(-s)]",
 	RunE:  getTableRegion,
 }
 
-func getTableRegion(_ *cobra.Command, args []string) error {
+func getTableRegion(c *cobra.Command, args []string) error {
 	if len(args) != 0 {
 		return fmt.Errorf("too many arguments")
 	}
-	return httpPrint(tablePrefix + tableDB + "/" + tableTable + "/" + regionSuffix)
+	if !regionSummary {
+		return httpPrint(tablePrefix + tableDB + "/" + tableTable + "/" + regionSuffix)
+	}
+	var body json.RawMessage
+	if err := httpGetJSON(tablePrefix+tableDB+"/"+tableTable+"/"+regionSuffix, &body); err != nil {
+		return err
+	}
+	// The regions of a partitioned table are returned by partition in an array.
+	var partitions []tableRegions
+	partitioned := bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+	if partitioned {
+		if err := json.Unmarshal(body, &partitions); err != nil {
+			return err
+		}
+		c.Printf("table %s: %d partitions\n", tableTable, len(partitions))
+	} else {
+		var regions tableRegions
+		if err := json.Unmarshal(body, &regions); err != nil {
+			return err
+		}
+		partitions = append(partitions, regions)
+	}
+	total := make(map[uint64]*storeRegionStat)
+	for _, regions := range partitions {
+		if partitioned {
+			c.Printf("partition %s (id: %d)\n", regions.TableName, regions.TableID)
+		} else {
+			c.Printf("table %s (id: %d)\n", regions.TableName, regions.TableID)
+		}
+		printRegionDistribution(c, "record", regions.RecordRegions, total)
+		for _, idx := range regions.Indices {
+			printRegionDistribution(c, fmt.Sprintf("index %s (id: %d)", idx.Name, idx.ID), idx.Regions, total)
+		}
+	}
+	c.Println("total:")
+	printStoreStats(c, total)
+
+	readFlow, err := getHotRegionFlow(pdHotReadPath)
+	if err != nil {
+		c.Printf("hot regions: unavailable: %v\n", err)
+		return nil
+	}
+	writeFlow, err := getHotRegionFlow(pdHotWritePath)
+	if err != nil {
+		c.Printf("hot regions: unavailable: %v\n", err)
+		return nil
+	}
+	c.Println("hot regions:")
+	for _, regions := range partitions {
+		prefix := ""
+		if partitioned {
+			prefix = "partition " + regions.TableName + " "
+		}
+		printHotRegions(c, prefix+"record", regions.RecordRegions, readFlow, writeFlow)
+		for _, idx := range regions.Indices {
+			printHotRegions(c, prefix+"index "+idx.Name, idx.Regions, readFlow, writeFlow)
+		}
+	}
+	return nil
+}
+
+func printRegionDistribution(c *cobra.Command, name string, regions []regionMeta, total map[uint64]*storeRegionStat) {
+	stats := make(map[uint64]*storeRegionStat)
+	for _, r := range regions {
+		for _, p := range r.Peers {
+			getStoreRegionStat(stats, p.StoreID).peers++
+		}
+		if r.Leader != nil && r.Leader.StoreID != 0 {
+			getStoreRegionStat(stats, r.Leader.StoreID).leaders++
+		}
+	}
+	for id, stat := range stats {
+		t := getStoreRegionStat(total, id)
+		t.peers += stat.peers
+		t.leaders += stat.leaders
+	}
+	c.Printf("%s: %d regions\n", name, len(regions))
+	printStoreStats(c, stats)
+}
+
+func getStoreRegionStat(stats map[uint64]*storeRegionStat, storeID uint64) *storeRegionStat {
+	stat, ok := stats[storeID]
+	if !ok {
+		stat = &storeRegionStat{}
+		stats[storeID] = stat
+	}
+	return stat
+}
+
+func printStoreStats(c *cobra.Command, stats map[uint64]*storeRegionStat) {
+	storeIDs := make([]uint64, 0, len(stats))
+	for id := range stats {
+		storeIDs = append(storeIDs, id)
+	}
+	sort.Slice(storeIDs, func(i, j int) bool { return storeIDs[i] < storeIDs[j] })
+	for _, id := range storeIDs {
+		c.Printf("  store %d: peers %d, leaders %d\n", id, stats[id].peers, stats[id].leaders)
+	}
+}
+
+func printHotRegions(c *cobra.Command, name string, regions []regionMeta, readFlow, writeFlow map[uint64]float64) {
+	for _, r := range regions {
+		read, isReadHot := readFlow[r.ID]
+		write, isWriteHot := writeFlow[r.ID]
+		if !isReadHot && !isWriteHot {
+			continue
+		}
+		c.Printf("  region %d (%s): read %s/s, write %s/s\n", r.ID, name, humanizeBytes(read), humanizeBytes(write))
+	}
+}
+
+// getHotRegionFlow returns the flow bytes of the hot regions by region ID.
+func getHotRegionFlow(path string) (map[uint64]float64, error) {
+	req, err := getRequest(path, http.MethodGet, "application/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := dial(req)
+	if err != nil {
+		return nil, err
+	}
+	var stats hotRegionStats
+	if err = json.Unmarshal([]byte(res), &stats); err != nil {
+		return nil, err
+	}
+	flow := make(map[uint64]float64)
+	for _, store := range stats.AsLeader {
+		if store == nil {
+			continue
+		}
+		for _, r := range store.Stats {
+			flow[r.RegionID] += r.FlowBytes
+		}
+	}
+	return flow, nil
 }
 
 var diskUsageCmd = &cobra.Command{
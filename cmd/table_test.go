@@ -0,0 +1,228 @@
+// Copyright 2020 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&tableTestSuite{})
+
+type tableTestSuite struct{}
+
+func (s *tableTestSuite) TestRegionSummary(c *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp string
+		switch r.URL.EscapedPath() {
+		case "/tables/test/t/regions":
+			resp = `{"name":"t","id":45,
+"record_regions":[
+	{"region_id":2,"leader":{"id":3,"store_id":1},"peers":[{"id":3,"store_id":1},{"id":4,"store_id":2}]},
+	{"region_id":5,"leader":{"id":6,"store_id":1},"peers":[{"id":6,"store_id":1},{"id":7,"store_id":2}]}],
+"indices":[{"name":"idx","id":1,"regions":[
+	{"region_id":8,"leader":{"id":10,"store_id":2},"peers":[{"id":9,"store_id":1},{"id":10,"store_id":2}]}]}]}`
+		case pdHotReadPath:
+			resp = `{"as_leader":{"1":{"statistics":[{"region_id":5,"flow_bytes":2048}]}}}`
+		case pdHotWritePath:
+			resp = `{"as_leader":{"2":{"statistics":[{"region_id":8,"flow_bytes":100}]}}}`
+		default:
+			c.Fatalf("unexpected path %s", r.URL.EscapedPath())
+		}
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(resp))
+		c.Assert(err, IsNil)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	c.Assert(err, IsNil)
+	uArr := strings.Split(u.Host, ":")
+	cmd := initCommand()
+	args := []string{"table", "regions", "-d", "test", "-t", "t", "--summary", "-H", uArr[0], "-P", uArr[1], "-i", uArr[0], "-p", uArr[1]}
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `table t (id: 45)
+record: 2 regions
+  store 1: peers 2, leaders 2
+  store 2: peers 2, leaders 0
+index idx (id: 1): 1 regions
+  store 1: peers 1, leaders 0
+  store 2: peers 1, leaders 1
+total:
+  store 1: peers 3, leaders 2
+  store 2: peers 3, leaders 1
+hot regions:
+  region 5 (record): read 2.0 KiB/s, write 0 B/s
+  region 8 (index idx): read 0 B/s, write 100 B/s
+`)
+}
+
+func (s *tableTestSuite) TestPartitionRegionSummary(c *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp string
+		switch r.URL.EscapedPath() {
+		case "/tables/test/pt/regions":
+			resp = `[{"name":"p0","id":46,
+"record_regions":[{"region_id":2,"leader":{"id":3,"store_id":1},"peers":[{"id":3,"store_id":1},{"id":4,"store_id":2}]}],
+"indices":[{"name":"idx","id":1,"regions":[{"region_id":8,"leader":{"id":10,"store_id":2},"peers":[{"id":9,"store_id":1},{"id":10,"store_id":2}]}]}]},
+{"name":"p1","id":47,
+"record_regions":[{"region_id":5,"leader":{"id":6,"store_id":2},"peers":[{"id":6,"store_id":2},{"id":7,"store_id":3}]}],
+"indices":[{"name":"idx","id":1,"regions":[]}]}]`
+		case pdHotReadPath:
+			resp = `{"as_leader":{"2":{"statistics":[{"region_id":5,"flow_bytes":2048}]}}}`
+		case pdHotWritePath:
+			resp = `{"as_leader":{}}`
+		default:
+			c.Fatalf("unexpected path %s", r.URL.EscapedPath())
+		}
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(resp))
+		c.Assert(err, IsNil)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	c.Assert(err, IsNil)
+	uArr := strings.Split(u.Host, ":")
+	cmd := initCommand()
+	args := []string{"table", "regions", "-d", "test", "-t", "pt", "--summary", "-H", uArr[0], "-P", uArr[1], "-i", uArr[0], "-p", uArr[1]}
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `table pt: 2 partitions
+partition p0 (id: 46)
+record: 1 regions
+  store 1: peers 1, leaders 1
+  store 2: peers 1, leaders 0
+index idx (id: 1): 1 regions
+  store 1: peers 1, leaders 0
+  store 2: peers 1, leaders 1
+partition p1 (id: 47)
+record: 1 regions
+  store 2: peers 1, leaders 1
+  store 3: peers 1, leaders 0
+index idx (id: 1): 0 regions
+total:
+  store 1: peers 2, leaders 1
+  store 2: peers 3, leaders 2
+  store 3: peers 1, leaders 0
+hot regions:
+  region 5 (partition p1 record): read 2.0 KiB/s, write 0 B/s
+`)
+}
+
+func (s *tableTestSuite) TestSplit(c *C) {
+	var splitKeys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp string
+		switch r.URL.EscapedPath() {
+		case "/schema/test/t":
+			resp = `{"id":45,"name":{"O":"t","L":"t"},` +
+				`"cols":[{"id":1,"name":{"O":"a","L":"a"},"offset":0,"type":{"Tp":8,"Flag":0}},` +
+				`{"id":2,"name":{"O":"b","L":"b"},"offset":1,"type":{"Tp":15,"Flag":0,"Collate":"utf8mb4_bin"}},` +
+				`{"id":3,"name":{"O":"c","L":"c"},"offset":2,"type":{"Tp":3,"Flag":32}},` +
+				`{"id":4,"name":{"O":"d","L":"d"},"offset":3,"type":{"Tp":246,"Flag":0}},` +
+				`{"id":5,"name":{"O":"e","L":"e"},"offset":4,"type":{"Tp":15,"Flag":0,"Collate":"utf8mb4_general_ci"}}],` +
+				`"index_info":[{"id":1,"idx_name":{"O":"idx","L":"idx"},"idx_cols":[{"name":{"O":"b","L":"b"},"offset":1}]},` +
+				`{"id":2,"idx_name":{"O":"idx_a","L":"idx_a"},"idx_cols":[{"name":{"O":"a","L":"a"},"offset":0}]},` +
+				`{"id":3,"idx_name":{"O":"idx_c","L":"idx_c"},"idx_cols":[{"name":{"O":"c","L":"c"},"offset":2}]},` +
+				`{"id":4,"idx_name":{"O":"idx_d","L":"idx_d"},"idx_cols":[{"name":{"O":"d","L":"d"},"offset":3}]},` +
+				`{"id":5,"idx_name":{"O":"idx_e","L":"idx_e"},"idx_cols":[{"name":{"O":"e","L":"e"},"offset":4}]}]}`
+		case pdSplitRegionsPath:
+			var input struct {
+				SplitKeys []string `json:"split_keys"`
+			}
+			c.Assert(json.NewDecoder(r.Body).Decode(&input), IsNil)
+			splitKeys = input.SplitKeys
+			resp = `{"processed-percentage":100,"regions-id":[10,11]}`
+		default:
+			c.Fatalf("unexpected path %s", r.URL.EscapedPath())
+		}
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(resp))
+		c.Assert(err, IsNil)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	c.Assert(err, IsNil)
+	uArr := strings.Split(u.Host, ":")
+	cmd := initCommand()
+	args := []string{"table", "split", "-d", "test", "-t", "t", "--lower", "0", "--upper", "100", "--regions", "2", "--dry-run",
+		"-H", uArr[0], "-P", uArr[1], "-i", uArr[0], "-p", uArr[1]}
+	_, output, err := executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `split keys:
+  74800000000000002D5F728000000000000000 (table_id: 45, handle: 0)
+  74800000000000002D5F728000000000000032 (table_id: 45, handle: 50)
+`)
+
+	args = []string{"table", "split", "-d", "test", "-t", "t", "--index", "idx", "--regions", "0", "--by", "abc", "--dry-run=false",
+		"-H", uArr[0], "-P", uArr[1], "-i", uArr[0], "-p", uArr[1]}
+	_, output, err = executeCommandC(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `split keys:
+  74800000000000002D5F698000000000000001016162630000000000FA (table_id: 45, index_id: 1, index_values: [abc])
+split into new regions: [10 11]
+`)
+	c.Assert(splitKeys, DeepEquals, []string{hex.EncodeToString(encodeBytes([]byte("t\x80\x00\x00\x00\x00\x00\x00\x2d_i\x80\x00\x00\x00\x00\x00\x00\x01\x01abc\x00\x00\x00\x00\x00\xfa")))})
+
+	// The values are encoded by the type of the first index column.
+	defer func() { splitBy = nil }()
+	split := func(index string, by ...string) (string, error) {
+		// The flag of the slice appends to the values of the last run.
+		splitBy = nil
+		args := []string{"table", "split", "-d", "test", "-t", "t", "--index", index, "--regions", "0", "--dry-run",
+			"-H", uArr[0], "-P", uArr[1], "-i", uArr[0], "-p", uArr[1]}
+		for _, v := range by {
+			args = append(args, "--by", v)
+		}
+		_, output, err := executeCommandC(cmd, args...)
+		return string(output), err
+	}
+	out, err := split("idx_a", "-1", "123")
+	c.Assert(err, IsNil)
+	c.Assert(out, Equals, `split keys:
+  74800000000000002D5F698000000000000002037FFFFFFFFFFFFFFF (table_id: 45, index_id: 2, index_values: [-1])
+  74800000000000002D5F69800000000000000203800000000000007B (table_id: 45, index_id: 2, index_values: [123])
+`)
+	_, err = split("idx_a", "abc")
+	c.Assert(err, ErrorMatches, "invalid value abc of bigint.*")
+	out, err = split("idx_c", "4294967295")
+	c.Assert(err, IsNil)
+	c.Assert(out, Matches, `split keys:
+  74800000000000002D5F6980000000000000030400000000FFFFFFFF .*
+`)
+	_, err = split("idx_c", "-1")
+	c.Assert(err, ErrorMatches, "invalid value -1 of int.* UNSIGNED.*")
+	_, err = split("idx_c", "4294967296")
+	c.Assert(err, ErrorMatches, "invalid value 4294967296 of int.* UNSIGNED.*")
+	out, err = split("idx", "123")
+	c.Assert(err, IsNil)
+	c.Assert(out, Matches, `split keys:
+  74800000000000002D5F698000000000000001013132330000000000FA .*
+`)
+	_, err = split("idx_e", "abc")
+	c.Assert(err, ErrorMatches, "cannot split index idx_e by column e: collation utf8mb4_general_ci is not supported, .*")
+	_, err = split("idx_d", "1")
+	c.Assert(err, ErrorMatches, "cannot split index idx_d by column d: column type decimal.* is not supported")
+	args = []string{"table", "split", "-d", "test", "-t", "t", "--index", "idx", "--lower", "0", "--upper", "100", "--regions", "2",
+		"--dry-run", "-H", uArr[0], "-P", uArr[1], "-i", uArr[0], "-p", uArr[1]}
+	splitBy = nil
+	_, _, err = executeCommandC(cmd, args...)
+	c.Assert(err, ErrorMatches, "--regions only works for integer columns, column b is varchar.*")
+}
@@ -3,10 +3,12 @@ module github.com/pingcap/tidb-ctl
 go 1.14
 
 require (
+	github.com/klauspost/compress v1.11.13
 	github.com/pingcap/check v0.0.0-20200212061837-5e12011dc712
 	github.com/pingcap/errors v0.11.5-0.20190809092503-95897b64e011
 	github.com/pingcap/parser v0.0.0-20200515083134-baa47367bc23
 	github.com/pingcap/tidb v1.1.0-beta.0.20200519125814-6098373c11a9
 	github.com/spf13/cobra v0.0.7-0.20200228181340-95f2f73ed97e
+	golang.org/x/crypto v0.0.0-20191206172530-e9b2fee46413
 	golang.org/x/sys v0.0.0-20220318055525-2edf467146b5 // indirect
 )